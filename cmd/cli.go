@@ -0,0 +1,782 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dylanewe/moni/internal/config"
+	"github.com/dylanewe/moni/internal/db"
+	"github.com/dylanewe/moni/internal/migrate"
+	"github.com/dylanewe/moni/internal/money"
+	"github.com/dylanewe/moni/internal/service"
+	"github.com/dylanewe/moni/internal/store"
+)
+
+// allTimeStart and allTimeEnd are the default date range of commands that
+// take -from and -to.
+const (
+	allTimeStart = "1900-01-01"
+	allTimeEnd   = "9999-12-31"
+)
+
+const usage = `usage: moni [command]
+
+Without a command moni starts the TUI.
+
+commands:
+  rules list                  list category rules
+  rules add [flags]           add a category rule (see moni rules add -h)
+  rules delete <id>           delete a category rule
+  classifier retrain          retrain the local categorizer and report its
+                              accuracy on the most recent transactions
+  tags list                   list tags with their transaction counts and totals
+  tags add <tag> <id>...      tag transactions
+  tags remove <tag> <id>...   untag transactions
+  tags show <tag> [flags]     list the transactions with a tag (-from, -to,
+                              -account)
+  transactions list [flags]   list transactions (see moni transactions list -h)
+  transactions show <id>      show a transaction with its splits and tags
+  transactions edit <id> [flags]
+                              change a transaction (-description, -category,
+                              -amount, -date, -account, -notes)
+  transactions delete <id>    delete a transaction
+  search [flags] <text>       search descriptions and notes, best matches
+                              first (-from, -to, -category, -limit)
+  accounts list               list accounts with their transaction counts
+  accounts add [flags]        add an account (see moni accounts add -h)
+  accounts delete <id>        delete an account and its rules, keeping its
+                              transactions
+  export [flags]              write transactions as CSV (-from, -to, -tag,
+                              -account)
+  migrate status              list schema migrations and when they were applied
+  migrate up                  apply pending schema migrations
+  migrate down [n]            roll back the last n migrations (default 1);
+                              the baseline cannot be rolled back
+`
+
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "rules":
+		return runRules(cfg, args[1:])
+	case "classifier":
+		return runClassifier(cfg, args[1:])
+	case "tags":
+		return runTags(cfg, args[1:])
+	case "transactions":
+		return runTransactions(cfg, args[1:])
+	case "search":
+		return runSearch(cfg, args[1:])
+	case "accounts":
+		return runAccounts(cfg, args[1:])
+	case "export":
+		return runExport(cfg, args[1:])
+	case "migrate":
+		return runMigrate(cfg, args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return nil
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("unknown command")
+	}
+}
+
+// openStore connects to the database and applies pending migrations, like
+// the TUI does on startup.
+func openStore(ctx context.Context, cfg *config.Config) (*sql.DB, *store.Store, error) {
+	conn, err := db.Open(ctx, cfg.DB.Address)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := db.Migrate(ctx, conn, cfg.DB.Address); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	s := store.NewStore(conn)
+	return conn, &s, nil
+}
+
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing subcommand")
+	}
+
+	ctx := context.Background()
+	conn, err := db.Open(ctx, cfg.DB.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	m, err := migrate.New(conn, db.Dialect(cfg.DB.Address))
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range status {
+			applied := "pending"
+			if !s.AppliedAt.IsZero() {
+				applied = s.AppliedAt.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+
+	case "up":
+		done, err := m.Up(ctx)
+		for _, mig := range done {
+			fmt.Printf("applied %s\n", mig)
+		}
+		if err == nil && len(done) == 0 {
+			fmt.Println("already up to date")
+		}
+		return err
+
+	case "down":
+		n := 1
+		if len(args) > 1 {
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				return fmt.Errorf("invalid count %q", args[1])
+			}
+		}
+		done, err := m.Down(ctx, n)
+		for _, mig := range done {
+			fmt.Printf("rolled back %s\n", mig)
+		}
+		return err
+
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+func runRules(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing subcommand")
+	}
+
+	ctx := context.Background()
+	conn, s, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch args[0] {
+	case "list":
+		rules, err := s.Rules.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		accounts, err := s.Accounts.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+		names := make(map[int64]string, len(accounts))
+		for _, a := range accounts {
+			names[a.ID] = a.Name
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPATTERN\tAMOUNT\tACCOUNT\tCATEGORY\tPRIORITY")
+		for _, r := range rules {
+			pattern := r.Pattern
+			if r.IsRegex {
+				pattern = "/" + pattern + "/"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\n",
+				r.ID, r.Name, pattern, amountRange(r.MinAmount, r.MaxAmount), names[r.AccountID], r.CategoryName, r.Priority)
+		}
+		return w.Flush()
+
+	case "add":
+		fs := flag.NewFlagSet("rules add", flag.ContinueOnError)
+		name := fs.String("name", "", "rule name")
+		pattern := fs.String("pattern", "", "description substring, '*' matches anything")
+		regex := fs.Bool("regex", false, "treat pattern as a regular expression")
+		minAmount := fs.String("min", "", "minimum absolute amount")
+		maxAmount := fs.String("max", "", "maximum absolute amount")
+		account := fs.String("account", "", "only match transactions imported into this account")
+		category := fs.String("category", "", "category to assign")
+		priority := fs.Int("priority", 0, "rules with a higher priority are tried first")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *pattern == "" || *category == "" {
+			return fmt.Errorf("-pattern and -category are required")
+		}
+		if *name == "" {
+			*name = *pattern
+		}
+
+		rule := store.Rule{
+			Name:         *name,
+			Pattern:      *pattern,
+			IsRegex:      *regex,
+			CategoryName: *category,
+			Priority:     *priority,
+		}
+		if rule.AccountID, err = findAccount(ctx, s, *account); err != nil {
+			return err
+		}
+		if rule.MinAmount, err = parseOptionalAmount(*minAmount); err != nil {
+			return err
+		}
+		if rule.MaxAmount, err = parseOptionalAmount(*maxAmount); err != nil {
+			return err
+		}
+
+		if err := s.Rules.Insert(ctx, &rule); err != nil {
+			return err
+		}
+		fmt.Printf("added rule %d\n", rule.ID)
+		return nil
+
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: moni rules delete <id>")
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid id %q", args[1])
+		}
+		return s.Rules.Delete(ctx, id)
+
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+func runClassifier(cfg *config.Config, args []string) error {
+	if len(args) == 0 || args[0] != "retrain" {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing subcommand")
+	}
+
+	ctx := context.Background()
+	conn, s, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	history, err := s.Transactions.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	categorizer, err := service.NewLocalCategorizer(cfg.Classifier)
+	if err != nil {
+		return err
+	}
+	eval, err := categorizer.Retrain(history)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("trained on %d transactions, saved to %s\n", eval.Train+eval.Test, categorizer.Path())
+	if eval.Test == 0 {
+		fmt.Println("not enough history to hold out transactions for evaluation")
+		return nil
+	}
+	fmt.Printf("held-out accuracy: %.1f%% (%d/%d most recent transactions, trained on %d older)\n",
+		eval.Accuracy()*100, eval.Correct, eval.Test, eval.Train)
+	fmt.Printf("at min confidence %.2f: %.1f%% accurate, covering %.1f%% of transactions\n",
+		categorizer.MinConfidence(), eval.ConfidentAccuracy()*100, eval.Coverage()*100)
+	return nil
+}
+
+func runTags(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing subcommand")
+	}
+
+	ctx := context.Background()
+	conn, s, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch args[0] {
+	case "list":
+		tags, err := s.Tags.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTAG\tTRANSACTIONS\tTOTAL")
+		for _, t := range tags {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%s\n", t.ID, t.Name, t.Transactions, t.Total)
+		}
+		return w.Flush()
+
+	case "add", "remove":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: moni tags %s <tag> <id>...", args[0])
+		}
+		ids, err := parseIDs(args[2:])
+		if err != nil {
+			return err
+		}
+		if args[0] == "add" {
+			return s.Tags.Tag(ctx, args[1], ids...)
+		}
+		return s.Tags.Untag(ctx, args[1], ids...)
+
+	case "show":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: moni tags show <tag> [-from date] [-to date]")
+		}
+		fs := flag.NewFlagSet("tags show", flag.ContinueOnError)
+		from := fs.String("from", allTimeStart, "first date, YYYY-MM-DD")
+		to := fs.String("to", allTimeEnd, "last date, YYYY-MM-DD")
+		account := fs.String("account", "", "only show transactions from this account")
+		if err := fs.Parse(args[2:]); err != nil {
+			return err
+		}
+
+		transactions, err := s.Tags.GetTransactions(ctx, args[1], *from, *to)
+		if err != nil {
+			return err
+		}
+		if transactions, err = filterAccount(ctx, s, transactions, *account); err != nil {
+			return err
+		}
+
+		var total money.Totals
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tDATE\tDESCRIPTION\tCATEGORY\tAMOUNT")
+		for _, t := range transactions {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", t.ID, t.Date, t.Description, t.CategoryName, t.Amount)
+			total = total.Add(t.Amount)
+		}
+		fmt.Fprintf(w, "\t\t\tTOTAL\t%s\n", total)
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+func runTransactions(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing subcommand")
+	}
+
+	ctx := context.Background()
+	conn, s, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch args[0] {
+	case "list":
+		fs := flag.NewFlagSet("transactions list", flag.ContinueOnError)
+		from := fs.String("from", "", "first date, YYYY-MM-DD")
+		to := fs.String("to", "", "last date, YYYY-MM-DD")
+		category := fs.String("category", "", "only list transactions in this category or its subcategories")
+		account := fs.String("account", "", "only list transactions from this account")
+		minAmount := fs.String("min", "", "minimum amount, negative for expenses")
+		maxAmount := fs.String("max", "", "maximum amount, negative for expenses")
+		description := fs.String("description", "", "only list descriptions containing this text")
+		tag := fs.String("tag", "", "only list transactions with this tag")
+		sortBy := fs.String("sort", store.SortColumns[0], "one of "+strings.Join(store.SortColumns, ", "))
+		desc := fs.Bool("desc", false, "sort in descending order")
+		limit := fs.Int("limit", 50, "transactions per page, 0 for all")
+		cursor := fs.String("cursor", "", "page to start from, as printed after the previous page")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		q := store.TransactionQuery{
+			StartDate:    *from,
+			EndDate:      *to,
+			CategoryName: *category,
+			Description:  *description,
+			Tag:          *tag,
+			Sort:         *sortBy,
+			Descending:   *desc,
+			Limit:        *limit,
+			Cursor:       *cursor,
+		}
+		if q.AccountID, err = findAccount(ctx, s, *account); err != nil {
+			return err
+		}
+		if q.MinAmount, err = parseOptionalAmount(*minAmount); err != nil {
+			return err
+		}
+		if q.MaxAmount, err = parseOptionalAmount(*maxAmount); err != nil {
+			return err
+		}
+
+		page, err := s.Transactions.List(ctx, q)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tDATE\tDESCRIPTION\tCATEGORY\tAMOUNT")
+		for _, t := range page.Transactions {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", t.ID, t.Date, t.Description, t.CategoryName, t.Amount)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		if page.NextCursor != "" {
+			fmt.Fprintf(os.Stderr, "more transactions: add -cursor %s\n", page.NextCursor)
+		}
+		return nil
+
+	case "show":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: moni transactions show <id>")
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid id %q", args[1])
+		}
+		t, err := s.Transactions.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "date\t%s\n", t.Date)
+		fmt.Fprintf(w, "description\t%s\n", t.Description)
+		fmt.Fprintf(w, "category\t%s\n", t.CategoryName)
+		fmt.Fprintf(w, "amount\t%s\n", t.Amount)
+		if t.AccountID != 0 {
+			fmt.Fprintf(w, "account\t%d\n", t.AccountID)
+		}
+		if t.Notes != "" {
+			fmt.Fprintf(w, "notes\t%s\n", t.Notes)
+		}
+		if len(t.Tags) > 0 {
+			fmt.Fprintf(w, "tags\t%s\n", strings.Join(t.Tags, ", "))
+		}
+		for _, split := range t.Splits {
+			fmt.Fprintf(w, "split\t%s %s\n", split.Amount, split.CategoryName)
+		}
+		return w.Flush()
+
+	case "edit":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: moni transactions edit <id> [flags]")
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid id %q", args[1])
+		}
+		t, err := s.Transactions.Get(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		fs := flag.NewFlagSet("transactions edit", flag.ContinueOnError)
+		fs.StringVar(&t.Description, "description", t.Description, "new description")
+		fs.StringVar(&t.CategoryName, "category", t.CategoryName, "new category, empty to clear it")
+		fs.StringVar(&t.Date, "date", t.Date, "new date, YYYY-MM-DD")
+		fs.StringVar(&t.Notes, "notes", t.Notes, "new notes")
+		amountFlag := fs.String("amount", "", "new amount, negative for expenses")
+		account := fs.String("account", "", "move to this account, \"none\" for no account")
+		if err := fs.Parse(args[2:]); err != nil {
+			return err
+		}
+		if *amountFlag != "" {
+			amount, err := money.Parse(*amountFlag)
+			if err != nil {
+				return err
+			}
+			t.Amount = amount.In(t.Amount.Currency)
+		}
+		switch *account {
+		case "":
+		case "none":
+			t.AccountID = 0
+		default:
+			if t.AccountID, err = findAccount(ctx, s, *account); err != nil {
+				return err
+			}
+		}
+
+		return s.Transactions.Update(ctx, &t)
+
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: moni transactions delete <id>")
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid id %q", args[1])
+		}
+		return s.Transactions.Delete(ctx, id)
+
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+func runSearch(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	from := fs.String("from", "", "first date, YYYY-MM-DD")
+	to := fs.String("to", "", "last date, YYYY-MM-DD")
+	category := fs.String("category", "", "only search transactions in this category or its subcategories")
+	limit := fs.Int("limit", 20, "maximum number of results, 0 for all")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: moni search [flags] <text>")
+	}
+
+	ctx := context.Background()
+	conn, s, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	results, err := s.Transactions.Search(ctx, store.SearchQuery{
+		Text:         strings.Join(fs.Args(), " "),
+		StartDate:    *from,
+		EndDate:      *to,
+		CategoryName: *category,
+		Limit:        *limit,
+	})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDATE\tDESCRIPTION\tCATEGORY\tAMOUNT\tNOTES")
+	for _, r := range results {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", r.ID, r.Date, r.Description, r.CategoryName, r.Amount, r.Notes)
+	}
+	return w.Flush()
+}
+
+func runExport(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	from := fs.String("from", allTimeStart, "first date, YYYY-MM-DD")
+	to := fs.String("to", allTimeEnd, "last date, YYYY-MM-DD")
+	tag := fs.String("tag", "", "only export transactions with this tag")
+	account := fs.String("account", "", "only export transactions from this account")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, s, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	q := store.TransactionQuery{StartDate: *from, EndDate: *to, Tag: *tag}
+	if q.AccountID, err = findAccount(ctx, s, *account); err != nil {
+		return err
+	}
+	page, err := s.Transactions.List(ctx, q)
+	if err != nil {
+		return err
+	}
+	tags, err := s.Tags.GetByDate(ctx, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"id", "date", "description", "category", "amount", "currency", "tags", "notes"})
+	for _, t := range page.Transactions {
+		w.Write([]string{
+			strconv.FormatInt(t.ID, 10),
+			t.Date,
+			t.Description,
+			t.CategoryName,
+			t.Amount.Decimal(),
+			t.Amount.Currency,
+			strings.Join(tags[t.ID], ";"),
+			t.Notes,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func runAccounts(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, usage)
+		return fmt.Errorf("missing subcommand")
+	}
+
+	ctx := context.Background()
+	conn, s, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	switch args[0] {
+	case "list":
+		accounts, err := s.Accounts.GetAll(ctx)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tTYPE\tINSTITUTION\tCURRENCY\tLAST 4\tTRANSACTIONS")
+		for _, a := range accounts {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%d\n", a.ID, a.Name, a.Type, a.Institution, a.Currency, a.Last4, a.Transactions)
+		}
+		return w.Flush()
+
+	case "add":
+		fs := flag.NewFlagSet("accounts add", flag.ContinueOnError)
+		name := fs.String("name", "", "account name, e.g. \"Joint checking\"")
+		typ := fs.String("type", store.AccountTypes[0], "one of "+strings.Join(store.AccountTypes, ", "))
+		institution := fs.String("institution", "", "bank or card issuer")
+		currency := fs.String("currency", "", "ISO currency code, e.g. USD")
+		last4 := fs.String("last4", "", "last four digits of the account or card number")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *name == "" {
+			return fmt.Errorf("-name is required")
+		}
+		if !slices.Contains(store.AccountTypes, *typ) {
+			return fmt.Errorf("invalid type %q", *typ)
+		}
+		if *currency != "" && len(*currency) != 3 {
+			return fmt.Errorf("invalid currency %q", *currency)
+		}
+		if _, err := strconv.Atoi(*last4); *last4 != "" && (len(*last4) != 4 || err != nil) {
+			return fmt.Errorf("invalid last 4 digits %q", *last4)
+		}
+
+		acc := store.Account{
+			Name:        *name,
+			Type:        *typ,
+			Institution: *institution,
+			Currency:    strings.ToUpper(*currency),
+			Last4:       *last4,
+		}
+		if err := s.Accounts.Insert(ctx, &acc); err != nil {
+			return err
+		}
+		fmt.Printf("added account %d\n", acc.ID)
+		return nil
+
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: moni accounts delete <id>")
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid id %q", args[1])
+		}
+		return s.Accounts.Delete(ctx, id)
+
+	default:
+		return fmt.Errorf("unknown subcommand %q", args[0])
+	}
+}
+
+// filterAccount keeps the transactions of the named account. An empty name
+// keeps them all.
+func filterAccount(ctx context.Context, s *store.Store, transactions []store.Transaction, name string) ([]store.Transaction, error) {
+	if name == "" {
+		return transactions, nil
+	}
+
+	id, err := findAccount(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []store.Transaction
+	for _, t := range transactions {
+		if t.AccountID == id {
+			kept = append(kept, t)
+		}
+	}
+	return kept, nil
+}
+
+// findAccount returns the ID of the named account, ignoring case. An empty
+// name returns 0.
+func findAccount(ctx context.Context, s *store.Store, name string) (int64, error) {
+	if name == "" {
+		return 0, nil
+	}
+
+	accounts, err := s.Accounts.GetAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	i := slices.IndexFunc(accounts, func(a store.Account) bool { return strings.EqualFold(a.Name, name) })
+	if i < 0 {
+		return 0, fmt.Errorf("unknown account %q", name)
+	}
+	return accounts[i].ID, nil
+}
+
+func parseIDs(args []string) ([]int64, error) {
+	ids := make([]int64, len(args))
+	for i, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", arg)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+func parseOptionalAmount(s string) (*money.Money, error) {
+	if s == "" {
+		return nil, nil
+	}
+	amount, err := money.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return &amount, nil
+}
+
+func amountRange(lo, hi *money.Money) string {
+	switch {
+	case lo != nil && hi != nil:
+		return fmt.Sprintf("%s-%s", lo, hi)
+	case lo != nil:
+		return fmt.Sprintf(">=%s", lo)
+	case hi != nil:
+		return fmt.Sprintf("<=%s", hi)
+	default:
+		return ""
+	}
+}
@@ -3,12 +3,11 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/dylanewe/moni/internal/config"
 	"github.com/dylanewe/moni/internal/service"
-	"github.com/openai/openai-go/v3"
-	"github.com/openai/openai-go/v3/option"
 )
 
 func main() {
@@ -16,9 +15,19 @@ func main() {
 	if err != nil {
 		log.Fatalf("get config error: %v", err)
 	}
+
+	if len(os.Args) > 1 {
+		if err := runCommand(&cfg, os.Args[1:]); err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	fmt.Print(cfg.Categories)
-	llmClient := openai.NewClient(option.WithAPIKey(cfg.LLM.APIKey))
-	service := service.NewService(&llmClient)
+	service, err := service.NewService(&cfg)
+	if err != nil {
+		log.Fatalf("service error: %v", err)
+	}
 
 	p := tea.NewProgram(initModel(&cfg, &service))
 	if _, err := p.Run(); err != nil {
@@ -2,15 +2,20 @@ package main
 
 import (
 	"fmt"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/dylanewe/moni/internal/config"
 	"github.com/dylanewe/moni/internal/db"
+	"github.com/dylanewe/moni/internal/money"
 	"github.com/dylanewe/moni/internal/service"
 	"github.com/dylanewe/moni/internal/store"
 	"github.com/dylanewe/moni/internal/tui"
@@ -36,9 +41,58 @@ const (
 	modeCategorize mode = "categorize"
 	modeSaving     mode = "saving"
 	modeLoading    mode = "loading"
-	modeDefault    mode = ""
+	modeImports    mode = "imports"
+	modeReimport   mode = "reimport"
+	modeReview     mode = "review"
+	modeRollback   mode = "rollback"
+	modeMerchants  mode = "merchants"
+	// modeCategories lists the categories; modeCategoryName edits the name
+	// of a new or renamed category and modeCategoryTarget picks where the
+	// transactions of a merged or deleted category go.
+	modeCategories     mode = "categories"
+	modeCategoryName   mode = "categoryname"
+	modeCategoryTarget mode = "categorytarget"
+	// modeTagInput edits the tags of a reviewed transaction or renames a
+	// tag listed in modeTags.
+	modeTags     mode = "tags"
+	modeTagInput mode = "taginput"
+	// modeSplit edits the splits of a reviewed transaction, with
+	// modeSplitInput adding a part.
+	modeSplit      mode = "split"
+	modeSplitInput mode = "splitinput"
+	// modeAccount picks the account of an imported statement, with
+	// modeAccountName adding a new one.
+	modeAccount     mode = "account"
+	modeAccountName mode = "accountname"
+	// modeTransactions browses the stored transactions, newest first, or
+	// the results of a search.
+	modeTransactions mode = "transactions"
+	modeDefault      mode = ""
 )
 
+// transactionPage is how many transactions the browser loads at a time.
+const transactionPage = 50
+
+const (
+	categoriesHelp = "[a]dd [r]ename [p]arent [m]erge [x] delete [esc] back"
+	reviewHelp     = "[space] keep/drop, [t] tags, [s] split, [enter] continue"
+	tagsHelp       = "[r]ename [x] delete [esc] back"
+	splitHelp      = "[a]dd [x] remove [b]alance [enter] save [esc] cancel"
+	accountHelp    = "[enter] confirm [n]ew account"
+	browseHelp     = "[/] search [n]ext page [esc] back"
+)
+
+// reviewItem is a parsed transaction awaiting confirmation before it is
+// categorized and saved.
+type reviewItem struct {
+	tx        *store.Transaction
+	rule      *store.Rule
+	learned   *store.Merchant
+	suggested *service.Suggestion
+	duplicate *store.Transaction
+	drop      bool
+}
+
 type command struct {
 	disabled bool
 	name     string
@@ -65,8 +119,41 @@ type model struct {
 	mode                mode
 	extractedTx         *db.ExtractStatementMsg
 	txCursor            int
+	pickerExpanded      map[int64]bool
+	pickerSearch        textinput.Model
 	uncategorizedTx     []*store.Transaction
 	uncategorizedCursor int
+	imports             []store.Import
+	importCursor        int
+	pendingStatement    string
+	review              []reviewItem
+	reviewCursor        int
+	learned             map[string]string
+	merchants           []store.Merchant
+	merchantCursor      int
+	categories          []store.Category
+	categoryCursor      int
+	categoryAction      string
+	categoryInput       textinput.Model
+	categoryTargets     []store.Category
+	targetCursor        int
+	tags                []store.Tag
+	tagCursor           int
+	tagAction           string
+	tagInput            textinput.Model
+	splitDraft          []store.Split
+	splitCursor         int
+	splitInput          textinput.Model
+	accounts            []store.Account
+	accountCursor       int
+	accountInput        textinput.Model
+	transactions        []store.Transaction
+	transactionCursor   int
+	transactionsNext    string
+	transactionSearch   textinput.Model
+	// transactionFilter holds the dates and category of a search without
+	// words, which browse the matching transactions page by page.
+	transactionFilter store.TransactionQuery
 }
 
 func initModel(cfg *config.Config, service *service.Service) model {
@@ -76,6 +163,33 @@ func initModel(cfg *config.Config, service *service.Service) model {
 
 	keys := tui.DefaultKeyMap()
 
+	input := textinput.New()
+	input.Placeholder = "Category name"
+	input.CharLimit = 100
+	input.Width = columnWidth - 4
+
+	tagInput := textinput.New()
+	tagInput.Placeholder = "tag, another-tag"
+	tagInput.Width = columnWidth - 4
+
+	splitInput := textinput.New()
+	splitInput.Placeholder = "category amount"
+	splitInput.Width = columnWidth - 4
+
+	accountInput := textinput.New()
+	accountInput.Placeholder = "name [last 4 digits]"
+	accountInput.Width = columnWidth - 4
+
+	search := textinput.New()
+	search.Prompt = "/ "
+	search.Placeholder = "search"
+	search.Width = columnWidth - 6
+
+	transactionSearch := textinput.New()
+	transactionSearch.Prompt = "/ "
+	transactionSearch.Placeholder = "words, from:YYYY-MM-DD to:YYYY-MM-DD category:name"
+	transactionSearch.Width = width - columnWidth - 12
+
 	return model{
 		spinner:          s,
 		loading:          true,
@@ -85,19 +199,30 @@ func initModel(cfg *config.Config, service *service.Service) model {
 			{name: "View Dashboard"},
 			{name: "Add Statement"},
 			{name: "Add Category"},
+			{name: "View Imports"},
+			{name: "Learned Categories"},
+			{name: "Tags"},
+			{name: "Transactions"},
 		},
-		cfg:         cfg,
-		service:     service,
-		currentView: listView,
-		keys:        keys,
-		mode:        modeLoading,
+		cfg:               cfg,
+		service:           service,
+		currentView:       listView,
+		keys:              keys,
+		mode:              modeLoading,
+		categoryInput:     input,
+		pickerSearch:      search,
+		tagInput:          tagInput,
+		splitInput:        splitInput,
+		accountInput:      accountInput,
+		transactionSearch: transactionSearch,
+		pickerExpanded:    make(map[int64]bool),
 	}
 }
 
 func (m model) Init() tea.Cmd {
 	addr := m.cfg.DB.Address
 	return tea.Batch(
-		db.Init(addr, m.cfg.Categories),
+		db.Init(addr, m.cfg.Categories, m.cfg.CategorySource, m.cfg.Currency),
 		m.spinner.Tick,
 	)
 }
@@ -106,8 +231,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// Typed names must reach the text input instead of the shortcuts.
+		if m.mode == modeCategoryName || m.mode == modeTagInput || m.mode == modeSplitInput ||
+			m.mode == modeAccountName || m.pickerSearch.Focused() || m.transactionSearch.Focused() {
+			break
+		}
 		switch {
 		case key.Matches(msg, m.keys.Quit):
+			// Inside a list screen q steps back instead of quitting.
+			if msg.String() == "q" && m.currentView == listView && m.mode != modeDefault {
+				break
+			}
 			return m, tea.Quit
 		case key.Matches(msg, m.keys.Dashboard):
 			m.currentView = dashboardView
@@ -125,8 +259,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		} else {
 			m.store = msg.Store
+			m.categories = msg.Categories
 			m.stateStatus = tui.StatusBarStateGreen
-			m.stateDescription = "Connected to database"
+			status := []string{"Connected to database"}
+			if len(msg.Migrated) > 0 {
+				status[0] += fmt.Sprintf(", applied %d migrations", len(msg.Migrated))
+			}
+			if len(msg.Added) > 0 {
+				status = append(status, fmt.Sprintf("added categories from config: %s", strings.Join(msg.Added, ", ")))
+			}
+			if len(msg.Unknown) > 0 {
+				m.stateStatus = tui.StatusBarStateYellow
+				status = append(status, fmt.Sprintf("categories not in config: %s", strings.Join(msg.Unknown, ", ")))
+			}
+			m.stateDescription = strings.Join(status, "; ")
 			m.dashboard = tui.NewDashboardModel(m.store, m.keys)
 			cmd = m.dashboard.Init()
 		}
@@ -144,36 +290,118 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	case listView:
 		switch msg := msg.(type) {
+		case db.CheckStatementMsg:
+			if msg.Err != nil {
+				m.loading = false
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeDefault
+				return m, nil
+			}
+			if len(msg.Previous) > 0 {
+				m.loading = false
+				m.pendingStatement = msg.File
+				m.stateStatus = tui.StatusBarStateYellow
+				m.stateDescription = fmt.Sprintf("Already imported on %s. Press [o] to import again",
+					msg.Previous[0].ImportedAt.Format("2006-01-02"))
+				m.mode = modeReimport
+				return m, nil
+			}
+			cmd = m.extractStatement(msg.File)
+			return m, cmd
+
 		case db.ExtractStatementMsg:
 			m.extractedTx = &msg
-			if m.extractedTx != nil {
-				if m.extractedTx.Err != nil {
-					m.stateStatus = tui.StatusBarStateRed
-					m.stateDescription = shortenErr(m.extractedTx.Err, 50)
-					m.mode = modeDefault
-				} else {
-					transactions := m.extractedTx.Transactions
-					for i := range transactions {
-						tx := &transactions[i]
-						if !slices.Contains(m.cfg.Categories, tx.CategoryName) {
-							m.uncategorizedTx = append(m.uncategorizedTx, tx)
-						}
-					}
+			if msg.Err != nil {
+				m.loading = false
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeDefault
+				return m, nil
+			}
+			m.stateDescription = "Detecting account..."
+			return m, db.DetectAccount(m.store, m.service, msg.File, msg.Transactions)
 
-					if len(m.uncategorizedTx) > 0 {
-						m.uncategorizedCursor = 0
-						m.stateDescription = "Categorize these transactions"
-						m.stateStatus = tui.StatusBarStateBlue
-						m.mode = modeCategorize
-					} else {
-						m.stateDescription = "Confirm to add these transactions?"
-						m.stateStatus = tui.StatusBarStateBlue
-						m.mode = modeSaving
-					}
+		case db.DetectAccountMsg:
+			m.loading = false
+			if msg.Err != nil {
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeDefault
+				return m, nil
+			}
+			m.accounts = msg.Accounts
+			m.stateStatus = tui.StatusBarStateBlue
+			if msg.Detected >= 0 {
+				m.accountCursor = msg.Detected
+				m.stateDescription = fmt.Sprintf("Statement looks like %s: %s", m.accounts[msg.Detected].Name, accountHelp)
+			} else {
+				// The cursor starts on "No account".
+				m.accountCursor = len(m.accounts)
+				m.stateDescription = "Pick the statement's account: " + accountHelp
+			}
+			m.mode = modeAccount
+			return m, nil
 
-				}
+		case db.AddAccountMsg:
+			m.loading = false
+			m.mode = modeAccount
+			if msg.Err != nil {
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				return m, nil
 			}
+			m.accounts = append(m.accounts, msg.Account)
+			m.accountCursor = len(m.accounts) - 1
+			m.stateStatus = tui.StatusBarStateGreen
+			m.stateDescription = fmt.Sprintf("Added account %s: %s", msg.Account.Name, accountHelp)
+			return m, nil
+
+		case db.ReviewMsg:
 			m.loading = false
+			if msg.Err != nil {
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeDefault
+				return m, nil
+			}
+
+			transactions := m.extractedTx.Transactions
+			if len(transactions) == 0 {
+				m.stateStatus = tui.StatusBarStateYellow
+				m.stateDescription = "No transactions found"
+				m.mode = modeDefault
+				return m, nil
+			}
+			m.review = make([]reviewItem, len(transactions))
+			for i := range transactions {
+				m.review[i].tx = &transactions[i]
+				// Rules are set up by the user, so they beat the parser's guess.
+				if rule, ok := msg.Rules[i]; ok {
+					m.review[i].rule = &rule
+					transactions[i].CategoryName = rule.CategoryName
+				} else if merchant, ok := msg.Learned[i]; ok {
+					m.review[i].learned = &merchant
+					transactions[i].CategoryName = merchant.CategoryName
+				} else if s, ok := msg.Suggested[i]; ok {
+					m.review[i].suggested = &s
+					transactions[i].CategoryName = s.Category
+				}
+				if dup, ok := msg.Duplicates[i]; ok {
+					m.review[i].duplicate = &dup
+					m.review[i].drop = true
+				}
+			}
+			m.reviewCursor = 0
+			m.learned = make(map[string]string)
+
+			m.stateDescription = "Review transactions: " + reviewHelp
+			m.stateStatus = tui.StatusBarStateBlue
+			if len(msg.Duplicates) > 0 {
+				m.stateDescription = fmt.Sprintf("%d possible duplicates marked: %s", len(msg.Duplicates), reviewHelp)
+				m.stateStatus = tui.StatusBarStateYellow
+			}
+			m.mode = modeReview
 			return m, nil
 
 		case db.AddStatementMsg:
@@ -182,22 +410,416 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.stateDescription = shortenErr(msg.Err, 50)
 			} else {
 				m.stateStatus = tui.StatusBarStateGreen
-				m.stateDescription = "Successfully added transactions!"
+				m.stateDescription = fmt.Sprintf("Successfully added %d transactions!", msg.Import.RowCount)
 			}
 			m.loading = false
 			m.mode = modeDefault
 			return m, nil
 
+		case db.LoadImportsMsg:
+			m.loading = false
+			if msg.Err != nil {
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeDefault
+				return m, nil
+			}
+			if len(msg.Imports) == 0 {
+				m.stateStatus = tui.StatusBarStateYellow
+				m.stateDescription = "No imports yet"
+				m.mode = modeDefault
+				return m, nil
+			}
+			m.imports = msg.Imports
+			m.importCursor = min(m.importCursor, len(m.imports)-1)
+			m.stateStatus = tui.StatusBarStateBlue
+			m.stateDescription = "Select an import to roll back"
+			m.mode = modeImports
+			return m, nil
+
+		case db.DeleteImportMsg:
+			if msg.Err != nil {
+				m.loading = false
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeDefault
+				return m, nil
+			}
+			return m, db.LoadImports(m.store)
+
+		case db.LoadMerchantsMsg:
+			m.loading = false
+			if msg.Err != nil {
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeDefault
+				return m, nil
+			}
+			if len(msg.Merchants) == 0 {
+				m.stateStatus = tui.StatusBarStateYellow
+				m.stateDescription = "No learned categories yet"
+				m.mode = modeDefault
+				return m, nil
+			}
+			m.merchants = msg.Merchants
+			m.merchantCursor = min(m.merchantCursor, len(m.merchants)-1)
+			m.stateStatus = tui.StatusBarStateBlue
+			m.stateDescription = "[x] Forget the selected merchant"
+			m.mode = modeMerchants
+			return m, nil
+
+		case db.ForgetMerchantMsg:
+			if msg.Err != nil {
+				m.loading = false
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeDefault
+				return m, nil
+			}
+			return m, db.LoadMerchants(m.store)
+
+		case db.LoadCategoriesMsg:
+			m.loading = false
+			if msg.Err != nil {
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeDefault
+				return m, nil
+			}
+			m.categories = msg.Categories
+			m.categoryCursor = max(0, min(m.categoryCursor, len(m.categories)-1))
+			m.stateStatus = tui.StatusBarStateBlue
+			m.stateDescription = categoriesHelp
+			m.mode = modeCategories
+			return m, nil
+
+		case db.UpdateCategoryMsg:
+			if msg.Err != nil {
+				m.loading = false
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeCategories
+				return m, nil
+			}
+			return m, db.LoadCategories(m.store)
+
+		case db.LoadTagsMsg:
+			m.loading = false
+			if msg.Err != nil {
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeDefault
+				return m, nil
+			}
+			if len(msg.Tags) == 0 {
+				m.stateStatus = tui.StatusBarStateYellow
+				m.stateDescription = "No tags yet, add them while reviewing a statement"
+				m.mode = modeDefault
+				return m, nil
+			}
+			m.tags = msg.Tags
+			m.tagCursor = min(m.tagCursor, len(m.tags)-1)
+			m.stateStatus = tui.StatusBarStateBlue
+			m.stateDescription = tagsHelp
+			m.mode = modeTags
+			return m, nil
+
+		case db.LoadTransactionsMsg:
+			m.loading = false
+			if msg.Err != nil {
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeDefault
+				return m, nil
+			}
+			if !msg.More {
+				if len(msg.Page.Transactions) == 0 && m.transactionFilter != (store.TransactionQuery{}) {
+					m.transactions = nil
+					m.transactionsNext = ""
+					m.stateStatus = tui.StatusBarStateYellow
+					m.stateDescription = "No transactions match the filters: [/] search again [esc] clear"
+					m.mode = modeTransactions
+					return m, nil
+				}
+				if len(msg.Page.Transactions) == 0 {
+					m.stateStatus = tui.StatusBarStateYellow
+					m.stateDescription = "No transactions yet, add a statement first"
+					m.mode = modeDefault
+					return m, nil
+				}
+				m.transactions = nil
+				m.transactionCursor = 0
+			}
+			m.transactions = append(m.transactions, msg.Page.Transactions...)
+			m.transactionsNext = msg.Page.NextCursor
+			m.stateStatus = tui.StatusBarStateBlue
+			m.stateDescription = browseHelp
+			m.mode = modeTransactions
+			return m, nil
+
+		case db.SearchTransactionsMsg:
+			m.loading = false
+			m.mode = modeTransactions
+			if msg.Err != nil {
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				return m, nil
+			}
+			m.transactions = nil
+			for _, r := range msg.Results {
+				m.transactions = append(m.transactions, r.Transaction)
+			}
+			m.transactionCursor = 0
+			m.transactionsNext = ""
+			m.stateStatus = tui.StatusBarStateBlue
+			m.stateDescription = fmt.Sprintf("%d matches for %q: [/] search again [esc] clear", len(m.transactions), msg.Query.Text)
+			if len(m.transactions) == 0 {
+				m.stateStatus = tui.StatusBarStateYellow
+				m.stateDescription = fmt.Sprintf("No matches for %q: [/] search again [esc] clear", msg.Query.Text)
+			}
+			return m, nil
+
+		case db.UpdateTagMsg:
+			if msg.Err != nil {
+				m.loading = false
+				m.stateStatus = tui.StatusBarStateRed
+				m.stateDescription = shortenErr(msg.Err, 50)
+				m.mode = modeTags
+				return m, nil
+			}
+			return m, db.LoadTags(m.store)
+
 		case tea.KeyMsg:
+			if m.mode == modeAccountName {
+				switch msg.Type {
+				case tea.KeyEnter:
+					acc := parseAccount(m.accountInput.Value())
+					if acc.Name == "" {
+						return m, nil
+					}
+					m.accountInput.Blur()
+					m.stateDescription = "Saving account..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					return m, db.AddAccount(m.store, acc)
+				case tea.KeyEsc:
+					m.accountInput.Blur()
+					m.stateStatus = tui.StatusBarStateBlue
+					m.stateDescription = "Pick the statement's account: " + accountHelp
+					m.mode = modeAccount
+					return m, nil
+				}
+				m.accountInput, cmd = m.accountInput.Update(msg)
+				return m, cmd
+			}
+
+			if m.mode == modeSplitInput {
+				switch msg.Type {
+				case tea.KeyEnter:
+					split, err := m.parseSplit(m.splitInput.Value())
+					if err != nil {
+						m.stateStatus = tui.StatusBarStateRed
+						m.stateDescription = shortenErr(err, 50)
+						return m, nil
+					}
+					m.splitInput.Blur()
+					m.splitDraft = append(m.splitDraft, split)
+					m.splitCursor = len(m.splitDraft) - 1
+					m.stateStatus = tui.StatusBarStateBlue
+					m.stateDescription = splitHelp
+					m.mode = modeSplit
+					return m, nil
+				case tea.KeyEsc:
+					m.splitInput.Blur()
+					m.stateStatus = tui.StatusBarStateBlue
+					m.stateDescription = splitHelp
+					m.mode = modeSplit
+					return m, nil
+				}
+				m.splitInput, cmd = m.splitInput.Update(msg)
+				return m, cmd
+			}
+
+			if m.mode == modeTagInput {
+				switch msg.Type {
+				case tea.KeyEnter:
+					m.tagInput.Blur()
+					if m.tagAction == "rename" {
+						m.stateDescription = "Renaming tag..."
+						m.stateStatus = tui.StatusBarStateYellow
+						m.mode = modeLoading
+						m.loading = true
+						return m, db.RenameTag(m.store, m.tags[m.tagCursor].ID, m.tagInput.Value())
+					}
+					m.review[m.reviewCursor].tx.Tags = parseTags(m.tagInput.Value())
+					m.stateDescription = "Review transactions: " + reviewHelp
+					m.mode = modeReview
+					return m, nil
+				case tea.KeyEsc:
+					m.tagInput.Blur()
+					if m.tagAction == "rename" {
+						m.stateDescription = tagsHelp
+						m.mode = modeTags
+					} else {
+						m.stateDescription = "Review transactions: " + reviewHelp
+						m.mode = modeReview
+					}
+					return m, nil
+				}
+				m.tagInput, cmd = m.tagInput.Update(msg)
+				return m, cmd
+			}
+
+			if m.mode == modeCategoryName {
+				switch msg.Type {
+				case tea.KeyEnter:
+					name := strings.TrimSpace(m.categoryInput.Value())
+					if name == "" {
+						return m, nil
+					}
+					m.categoryInput.Blur()
+					m.stateDescription = "Saving category..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					if m.categoryAction == "rename" {
+						return m, db.RenameCategory(m.store, m.categories[m.categoryCursor].ID, name)
+					}
+					return m, db.AddCategory(m.store, name)
+				case tea.KeyEsc:
+					m.categoryInput.Blur()
+					m.stateDescription = categoriesHelp
+					m.stateStatus = tui.StatusBarStateBlue
+					m.mode = modeCategories
+					return m, nil
+				}
+				m.categoryInput, cmd = m.categoryInput.Update(msg)
+				return m, cmd
+			}
+
+			if m.transactionSearch.Focused() {
+				switch msg.Type {
+				case tea.KeyEnter:
+					q, err := m.parseSearch(m.transactionSearch.Value())
+					if err != nil {
+						m.stateStatus = tui.StatusBarStateRed
+						m.stateDescription = shortenErr(err, 50)
+						return m, nil
+					}
+					m.transactionSearch.Blur()
+					m.transactionFilter = store.TransactionQuery{}
+					if q.Text == "" {
+						m.transactionFilter = store.TransactionQuery{StartDate: q.StartDate, EndDate: q.EndDate, CategoryName: q.CategoryName}
+						return m, m.loadTransactions()
+					}
+					m.stateDescription = "Searching..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					q.Limit = transactionPage
+					return m, db.SearchTransactions(m.store, q)
+				case tea.KeyEsc:
+					m.transactionSearch.Blur()
+					m.stateDescription = browseHelp
+					return m, nil
+				}
+				m.transactionSearch, cmd = m.transactionSearch.Update(msg)
+				return m, cmd
+			}
+
+			if m.pickerSearch.Focused() {
+				switch msg.Type {
+				case tea.KeyEnter:
+					if rows := m.pickerRows(); len(rows) > 0 {
+						m.categorize(rows[m.txCursor].Ref)
+					}
+					return m, nil
+				case tea.KeyEsc:
+					m.pickerSearch.SetValue("")
+					m.pickerSearch.Blur()
+					m.txCursor = 0
+					return m, nil
+				case tea.KeyUp:
+					m.txCursor = max(m.txCursor-1, 0)
+					return m, nil
+				case tea.KeyDown:
+					m.txCursor = max(0, min(m.txCursor+1, len(m.pickerRows())-1))
+					return m, nil
+				}
+				m.pickerSearch, cmd = m.pickerSearch.Update(msg)
+				m.txCursor = 0
+				return m, cmd
+			}
+
 			switch msg.String() {
 			case tea.KeyEnter.String():
+				if m.mode == modeAccount {
+					m.extractedTx.Import.AccountID = 0
+					if m.accountCursor < len(m.accounts) {
+						m.extractedTx.Import.AccountID = m.accounts[m.accountCursor].ID
+					}
+					// Rules limited to an account match on the transactions'
+					// account, which is picked again after stepping back.
+					for i := range m.extractedTx.Transactions {
+						m.extractedTx.Transactions[i].AccountID = m.extractedTx.Import.AccountID
+					}
+					m.stateDescription = "Applying rules and checking for duplicates..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					return m, db.PrepareReview(m.store, m.service, m.extractedTx.Transactions)
+				}
+
+				if m.mode == modeSplit {
+					tx := m.review[m.reviewCursor].tx
+					if err := store.ValidateSplits(tx.Amount, m.splitDraft); err != nil {
+						m.stateStatus = tui.StatusBarStateRed
+						m.stateDescription = shortenErr(err, 50)
+						return m, nil
+					}
+					tx.Splits = m.splitDraft
+					// The largest part stands in as the transaction's category.
+					var largest int64
+					for _, s := range tx.Splits {
+						if s.Amount.Abs().Cents > largest {
+							largest = s.Amount.Abs().Cents
+							tx.CategoryName = s.CategoryName
+						}
+					}
+					m.stateStatus = tui.StatusBarStateBlue
+					m.stateDescription = "Review transactions: " + reviewHelp
+					m.mode = modeReview
+					return m, nil
+				}
+
+				if m.mode == modeCategoryTarget {
+					from := m.categories[m.categoryCursor]
+					into := m.categoryTargets[m.targetCursor]
+					m.stateDescription = "Updating categories..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					switch m.categoryAction {
+					case "merge":
+						return m, db.MergeCategory(m.store, from.ID, into.ID)
+					case "parent":
+						return m, db.SetCategoryParent(m.store, from.ID, into.ID)
+					}
+					return m, db.DeleteCategory(m.store, from.ID, into.ID)
+				}
+
+				if m.mode == modeCategories {
+					return m, nil
+				}
+
 				if m.mode == modeFilePicker {
-					m.stateDescription = "Parsing statement..."
+					m.stateDescription = "Checking statement..."
 					m.stateStatus = tui.StatusBarStateYellow
 					filepath := "../statements/" + m.fileStatements[m.fileCursor]
 					m.mode = modeLoading
 					m.loading = true
-					return m, db.ExtractStatement(m.service.LLMParser, m.cfg.Categories, filepath)
+					return m, db.CheckStatement(m.store, filepath)
 				}
 
 				if m.mode == modeSaving {
@@ -205,20 +827,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.stateStatus = tui.StatusBarStateYellow
 					m.mode = modeLoading
 					m.loading = true
-					return m, db.AddStatement(m.store, m.extractedTx.Transactions)
+					return m, db.AddStatement(m.store, m.extractedTx.Import, m.extractedTx.Transactions, m.learned, m.statementCurrency())
+				}
+
+				if m.mode == modeReview {
+					m.finishReview()
+					return m, nil
 				}
 
 				if m.mode == modeCategorize {
-					currentTx := m.uncategorizedTx[m.uncategorizedCursor]
-					selectedCategory := m.cfg.Categories[m.txCursor]
-					currentTx.CategoryName = selectedCategory
-					if m.uncategorizedCursor < len(m.uncategorizedTx)-1 {
-						m.uncategorizedCursor++
-						m.txCursor = 0
-					} else {
-						m.stateDescription = "Confirm to add these transactions?"
-						m.stateStatus = tui.StatusBarStateBlue
-						m.mode = modeSaving
+					if rows := m.pickerRows(); len(rows) > 0 {
+						m.categorize(rows[m.txCursor].Ref)
 					}
 					return m, nil
 				}
@@ -228,11 +847,57 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.stateStatus = tui.StatusBarStateYellow
 					m.mode = modeLoading
 					m.loading = true
-					return m, db.AddStatement(m.store, m.extractedTx.Transactions)
+					return m, db.AddStatement(m.store, m.extractedTx.Import, m.extractedTx.Transactions, m.learned, m.statementCurrency())
+				}
+
+				if m.mode == modeImports {
+					imp := m.imports[m.importCursor]
+					m.stateDescription = fmt.Sprintf("Delete %s and its %d transactions? [y/n]", imp.FileName, imp.RowCount)
+					m.stateStatus = tui.StatusBarStateRed
+					m.mode = modeRollback
+					return m, nil
+				}
+
+				if m.cursor == 2 {
+					m.stateDescription = "Loading categories..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					return m, db.LoadCategories(m.store)
+				}
+
+				if m.cursor == 6 {
+					m.transactionSearch.SetValue("")
+					m.transactionFilter = store.TransactionQuery{}
+					return m, m.loadTransactions()
+				}
+
+				if m.cursor == 5 {
+					m.stateDescription = "Loading tags..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					return m, db.LoadTags(m.store)
+				}
+
+				if m.cursor == 4 {
+					m.stateDescription = "Loading learned categories..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					return m, db.LoadMerchants(m.store)
+				}
+
+				if m.cursor == 3 {
+					m.stateDescription = "Loading imports..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					return m, db.LoadImports(m.store)
 				}
 
 				if m.cursor == 1 {
-					statements, err := util.ReadFilesFromFolder("../statements/", []string{".pdf"})
+					statements, err := util.ReadFilesFromFolder("../statements/", service.StatementExtensions)
 					if err != nil {
 						m.stateDescription = "Error reading statements folder"
 						m.stateStatus = tui.StatusBarStateRed
@@ -250,13 +915,41 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 
 			case "ctrl+c", "q":
-				if m.mode == modeFilePicker {
+				if m.mode == modeFilePicker || m.mode == modeImports || m.mode == modeMerchants || m.mode == modeCategories ||
+					m.mode == modeTransactions || m.mode == modeTags {
+					m.stateDescription = ""
 					m.mode = modeDefault
 					return m, nil
 				}
-				return m, tea.Quit
-
-			case "up", "k":
+				if m.mode == modeReimport || m.mode == modeAccount {
+					m.stateDescription = "Pick a financial statement to add"
+					m.stateStatus = tui.StatusBarStateBlue
+					m.mode = modeFilePicker
+					return m, nil
+				}
+				// Going back from the review keeps the parsed statement, so
+				// it is not paid for twice.
+				if m.mode == modeReview {
+					m.stateDescription = "Pick the statement's account: " + accountHelp
+					m.stateStatus = tui.StatusBarStateBlue
+					m.mode = modeAccount
+					return m, nil
+				}
+				if m.mode == modeSplit {
+					m.stateDescription = "Review transactions: " + reviewHelp
+					m.stateStatus = tui.StatusBarStateBlue
+					m.mode = modeReview
+					return m, nil
+				}
+				if m.mode == modeRollback {
+					m.stateDescription = "Select an import to roll back"
+					m.stateStatus = tui.StatusBarStateBlue
+					m.mode = modeImports
+					return m, nil
+				}
+				return m, tea.Quit
+
+			case "up", "k":
 				if m.mode == modeFilePicker {
 					if m.fileCursor > 0 {
 						m.fileCursor--
@@ -265,6 +958,42 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					if m.txCursor > 0 {
 						m.txCursor--
 					}
+				} else if m.mode == modeImports {
+					if m.importCursor > 0 {
+						m.importCursor--
+					}
+				} else if m.mode == modeReview {
+					if m.reviewCursor > 0 {
+						m.reviewCursor--
+					}
+				} else if m.mode == modeMerchants {
+					if m.merchantCursor > 0 {
+						m.merchantCursor--
+					}
+				} else if m.mode == modeTags {
+					if m.tagCursor > 0 {
+						m.tagCursor--
+					}
+				} else if m.mode == modeTransactions {
+					if m.transactionCursor > 0 {
+						m.transactionCursor--
+					}
+				} else if m.mode == modeSplit {
+					if m.splitCursor > 0 {
+						m.splitCursor--
+					}
+				} else if m.mode == modeAccount {
+					if m.accountCursor > 0 {
+						m.accountCursor--
+					}
+				} else if m.mode == modeCategories {
+					if m.categoryCursor > 0 {
+						m.categoryCursor--
+					}
+				} else if m.mode == modeCategoryTarget {
+					if m.targetCursor > 0 {
+						m.targetCursor--
+					}
 				} else {
 					if m.cursor > 0 {
 						if m.commands[m.cursor-1].disabled {
@@ -280,9 +1009,46 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 						m.fileCursor++
 					}
 				} else if m.mode == modeCategorize {
-					if m.txCursor < len(m.cfg.Categories)-1 {
+					if m.txCursor < len(m.pickerRows())-1 {
 						m.txCursor++
 					}
+				} else if m.mode == modeImports {
+					if m.importCursor < len(m.imports)-1 {
+						m.importCursor++
+					}
+				} else if m.mode == modeReview {
+					if m.reviewCursor < len(m.review)-1 {
+						m.reviewCursor++
+					}
+				} else if m.mode == modeMerchants {
+					if m.merchantCursor < len(m.merchants)-1 {
+						m.merchantCursor++
+					}
+				} else if m.mode == modeTags {
+					if m.tagCursor < len(m.tags)-1 {
+						m.tagCursor++
+					}
+				} else if m.mode == modeTransactions {
+					if m.transactionCursor < len(m.transactions)-1 {
+						m.transactionCursor++
+					}
+				} else if m.mode == modeSplit {
+					if m.splitCursor < len(m.splitDraft)-1 {
+						m.splitCursor++
+					}
+				} else if m.mode == modeAccount {
+					// The last row is "No account".
+					if m.accountCursor < len(m.accounts) {
+						m.accountCursor++
+					}
+				} else if m.mode == modeCategories {
+					if m.categoryCursor < len(m.categories)-1 {
+						m.categoryCursor++
+					}
+				} else if m.mode == modeCategoryTarget {
+					if m.targetCursor < len(m.categoryTargets)-1 {
+						m.targetCursor++
+					}
 				} else {
 					if m.cursor < len(m.commands)-1 {
 						if m.commands[m.cursor+1].disabled {
@@ -292,13 +1058,250 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 				}
 
+			case " ":
+				if m.mode == modeReview && len(m.review) > 0 {
+					m.review[m.reviewCursor].drop = !m.review[m.reviewCursor].drop
+					return m, nil
+				}
+
+			case "/":
+				if m.mode == modeCategorize {
+					m.pickerSearch.Focus()
+					return m, textinput.Blink
+				}
+
+				if m.mode == modeTransactions {
+					m.transactionSearch.SetValue("")
+					m.transactionSearch.Focus()
+					m.stateDescription = "Search descriptions and notes, filter with from: to: category: [enter] search [esc] cancel"
+					return m, textinput.Blink
+				}
+
+			case "right", "l":
+				if m.mode == modeCategorize {
+					if rows := m.pickerRows(); len(rows) > 0 && m.hasSubcategories(rows[m.txCursor].ID) {
+						m.pickerExpanded[rows[m.txCursor].ID] = true
+					}
+					return m, nil
+				}
+
+			case "left", "h":
+				if m.mode == modeCategorize {
+					rows := m.pickerRows()
+					if len(rows) == 0 {
+						return m, nil
+					}
+					current := rows[m.txCursor]
+					if m.pickerExpanded[current.ID] {
+						m.pickerExpanded[current.ID] = false
+						return m, nil
+					}
+					// Collapse the parent and move onto it.
+					if current.ParentID != 0 {
+						m.pickerExpanded[current.ParentID] = false
+						for i, c := range m.pickerRows() {
+							if c.ID == current.ParentID {
+								m.txCursor = i
+							}
+						}
+					}
+					return m, nil
+				}
+
+			case "s":
+				if m.mode == modeReview && len(m.review) > 0 {
+					m.splitDraft = slices.Clone(m.review[m.reviewCursor].tx.Splits)
+					m.splitCursor = 0
+					m.stateStatus = tui.StatusBarStateBlue
+					m.stateDescription = splitHelp
+					m.mode = modeSplit
+					return m, nil
+				}
+
+			case "b":
+				if m.mode == modeSplit && len(m.splitDraft) > 0 {
+					tx := m.review[m.reviewCursor].tx
+					remainder := store.SplitRemainder(tx.Amount, m.splitDraft)
+					balanced, err := m.splitDraft[m.splitCursor].Amount.TryAdd(remainder)
+					if err != nil {
+						m.stateStatus = tui.StatusBarStateRed
+						m.stateDescription = shortenErr(err, 50)
+						return m, nil
+					}
+					m.splitDraft[m.splitCursor].Amount = balanced
+					return m, nil
+				}
+
+			case "t":
+				if m.mode == modeReview && len(m.review) > 0 {
+					m.tagAction = "review"
+					m.tagInput.SetValue(strings.Join(m.review[m.reviewCursor].tx.Tags, ", "))
+					m.tagInput.CursorEnd()
+					m.tagInput.Focus()
+					m.stateDescription = "Comma separated tags: [enter] save [esc] cancel"
+					m.mode = modeTagInput
+					return m, textinput.Blink
+				}
+
+			case "esc":
+				if m.mode == modeSplit {
+					m.stateStatus = tui.StatusBarStateBlue
+					m.stateDescription = "Review transactions: " + reviewHelp
+					m.mode = modeReview
+					return m, nil
+				}
+
+				if m.mode == modeCategories || m.mode == modeTags {
+					m.stateDescription = ""
+					m.mode = modeDefault
+					return m, nil
+				}
+
+				if m.mode == modeTransactions {
+					if m.transactionSearch.Value() != "" {
+						m.transactionSearch.SetValue("")
+						m.transactionFilter = store.TransactionQuery{}
+						return m, m.loadTransactions()
+					}
+					m.stateDescription = ""
+					m.mode = modeDefault
+					return m, nil
+				}
+				if m.mode == modeCategoryTarget {
+					m.stateDescription = categoriesHelp
+					m.stateStatus = tui.StatusBarStateBlue
+					m.mode = modeCategories
+					return m, nil
+				}
+
+			case "a":
+				if m.mode == modeCategories {
+					m.editCategoryName("add", "")
+					return m, textinput.Blink
+				}
+
+				if m.mode == modeSplit {
+					m.splitInput.SetValue("")
+					m.splitInput.Focus()
+					m.stateDescription = "Category and amount, the amount defaults to what is left"
+					m.mode = modeSplitInput
+					return m, textinput.Blink
+				}
+
+			case "r":
+				if m.mode == modeCategories && len(m.categories) > 0 {
+					m.editCategoryName("rename", m.categories[m.categoryCursor].Name)
+					return m, textinput.Blink
+				}
+
+				if m.mode == modeTags {
+					m.tagAction = "rename"
+					m.tagInput.SetValue(m.tags[m.tagCursor].Name)
+					m.tagInput.CursorEnd()
+					m.tagInput.Focus()
+					m.stateDescription = "[enter] save [esc] cancel"
+					m.mode = modeTagInput
+					return m, textinput.Blink
+				}
+
+			case "m":
+				if m.mode == modeCategories && len(m.categories) > 1 {
+					m.pickCategoryTarget("merge")
+					return m, nil
+				}
+
+			case "p":
+				if m.mode == modeCategories && len(m.categories) > 0 {
+					m.pickCategoryTarget("parent")
+					return m, nil
+				}
+
+			case "x":
+				if m.mode == modeCategories && len(m.categories) > 0 {
+					m.pickCategoryTarget("delete")
+					return m, nil
+				}
+
+				if m.mode == modeSplit && len(m.splitDraft) > 0 {
+					m.splitDraft = slices.Delete(m.splitDraft, m.splitCursor, m.splitCursor+1)
+					m.splitCursor = max(0, min(m.splitCursor, len(m.splitDraft)-1))
+					return m, nil
+				}
+
+				if m.mode == modeTags {
+					m.stateDescription = "Deleting tag..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					return m, db.DeleteTag(m.store, m.tags[m.tagCursor].ID)
+				}
+
+				if m.mode == modeMerchants {
+					m.stateDescription = "Forgetting..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					return m, db.ForgetMerchant(m.store, m.merchants[m.merchantCursor].ID)
+				}
+
+			case "o":
+				if m.mode == modeReimport {
+					m.loading = true
+					m.mode = modeLoading
+					cmd = m.extractStatement(m.pendingStatement)
+					return m, cmd
+				}
+
+			case "n":
+				if m.mode == modeTransactions && m.transactionsNext != "" {
+					m.stateDescription = "Loading transactions..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					q := m.transactionFilter
+					q.Descending = true
+					q.Limit = transactionPage
+					q.Cursor = m.transactionsNext
+					return m, db.LoadTransactions(m.store, q, true)
+				}
+
+				if m.mode == modeAccount {
+					m.accountInput.SetValue("")
+					m.accountInput.Focus()
+					m.stateDescription = "Name the account, optionally followed by its last 4 digits"
+					m.mode = modeAccountName
+					return m, textinput.Blink
+				}
+
+				if m.mode == modeReimport {
+					m.stateDescription = "Pick a financial statement to add"
+					m.stateStatus = tui.StatusBarStateBlue
+					m.mode = modeFilePicker
+					return m, nil
+				}
+
+				if m.mode == modeRollback {
+					m.stateDescription = "Select an import to roll back"
+					m.stateStatus = tui.StatusBarStateBlue
+					m.mode = modeImports
+					return m, nil
+				}
+
 			case "y":
+				if m.mode == modeRollback {
+					m.stateDescription = "Rolling back import..."
+					m.stateStatus = tui.StatusBarStateYellow
+					m.mode = modeLoading
+					m.loading = true
+					return m, db.DeleteImport(m.store, m.imports[m.importCursor].ID)
+				}
+
 				if m.mode == modeSaving {
 					m.stateDescription = "Saving..."
 					m.stateStatus = tui.StatusBarStateYellow
 					m.mode = modeLoading
 					m.loading = true
-					return m, db.AddStatement(m.store, m.extractedTx.Transactions)
+					return m, db.AddStatement(m.store, m.extractedTx.Import, m.extractedTx.Transactions, m.learned, m.statementCurrency())
 				}
 			}
 		}
@@ -311,6 +1314,317 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// finishReview drops the transactions the user rejected and moves on to
+// categorizing whatever is left without a known category.
+func (m *model) finishReview() {
+	var kept []store.Transaction
+	for _, item := range m.review {
+		if !item.drop {
+			kept = append(kept, *item.tx)
+		}
+	}
+	m.review = nil
+	m.extractedTx.Transactions = kept
+
+	if len(kept) == 0 {
+		m.stateDescription = "Nothing left to import"
+		m.stateStatus = tui.StatusBarStateYellow
+		m.mode = modeDefault
+		return
+	}
+
+	m.uncategorizedTx = nil
+	for i := range kept {
+		tx := &kept[i]
+		if !slices.Contains(m.categoryNames(), tx.CategoryName) {
+			m.uncategorizedTx = append(m.uncategorizedTx, tx)
+		}
+	}
+
+	if len(m.uncategorizedTx) > 0 {
+		m.uncategorizedCursor = 0
+		m.txCursor = 0
+		m.stateDescription = "Categorize: [/] search [l] expand [h] collapse"
+		m.stateStatus = tui.StatusBarStateBlue
+		m.mode = modeCategorize
+	} else {
+		m.stateDescription = "Confirm to add these transactions?"
+		m.stateStatus = tui.StatusBarStateBlue
+		m.mode = modeSaving
+	}
+
+	if partial := m.extractedTx.Partial; partial != nil {
+		m.stateDescription = fmt.Sprintf("%s (%d of %d chunks failed to parse)",
+			m.stateDescription, len(partial.Errors), partial.Total)
+		m.stateStatus = tui.StatusBarStateYellow
+	}
+}
+
+// parseAccount reads a new account such as "Joint checking 1234", where the
+// trailing digits are the last four of the account number.
+func parseAccount(s string) store.Account {
+	fields := strings.Fields(s)
+	var acc store.Account
+	if n := len(fields); n > 1 && len(fields[n-1]) == 4 {
+		if _, err := strconv.Atoi(fields[n-1]); err == nil {
+			acc.Last4 = fields[n-1]
+			fields = fields[:n-1]
+		}
+	}
+	acc.Name = strings.Join(fields, " ")
+	return acc
+}
+
+// accountLabel describes an account in the import picker.
+func accountLabel(a store.Account) string {
+	details := []string{a.Type}
+	if a.Institution != "" {
+		details = append(details, a.Institution)
+	}
+	if a.Last4 != "" {
+		details = append(details, "*"+a.Last4)
+	}
+	return fmt.Sprintf("%s (%s)", a.Name, strings.Join(details, ", "))
+}
+
+// parseSplit reads a split part such as "groceries 23.50". Without an amount
+// the part takes whatever is not allocated yet.
+func (m *model) parseSplit(s string) (store.Split, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return store.Split{}, fmt.Errorf("enter a category and an amount")
+	}
+
+	tx := m.review[m.reviewCursor].tx
+	amount := store.SplitRemainder(tx.Amount, m.splitDraft)
+	if parsed, err := money.Parse(fields[len(fields)-1]); err == nil && len(fields) > 1 {
+		amount = parsed.In(tx.Amount.Currency)
+		fields = fields[:len(fields)-1]
+	}
+
+	name := strings.Join(fields, " ")
+	for _, c := range m.categories {
+		if strings.EqualFold(c.Ref, name) || strings.EqualFold(c.Path, name) {
+			return store.Split{CategoryName: c.Ref, Amount: amount}, nil
+		}
+	}
+	return store.Split{}, fmt.Errorf("unknown category %q", name)
+}
+
+// parseTags reads a comma separated list of tags, dropping duplicates.
+func parseTags(s string) []string {
+	var tags []string
+	for _, t := range strings.Split(s, ",") {
+		if t = store.NormalizeTag(t); t != "" && !slices.Contains(tags, t) {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// categorize files the current uncategorized transaction under category and
+// moves on to the next one.
+func (m *model) categorize(category string) {
+	currentTx := m.uncategorizedTx[m.uncategorizedCursor]
+	currentTx.CategoryName = category
+	if pattern := service.MerchantPattern(currentTx.Description); pattern != "" {
+		m.learned[pattern] = category
+	}
+
+	m.pickerSearch.SetValue("")
+	m.pickerSearch.Blur()
+	m.txCursor = 0
+	if m.uncategorizedCursor < len(m.uncategorizedTx)-1 {
+		m.uncategorizedCursor++
+	} else {
+		m.stateDescription = "Confirm to add these transactions?"
+		m.stateStatus = tui.StatusBarStateBlue
+		m.mode = modeSaving
+	}
+}
+
+// pickerRows lists the categories offered while categorizing: the matches of
+// the search, or the tree with only expanded categories opened.
+func (m *model) pickerRows() []store.Category {
+	var rows []store.Category
+	if query := strings.ToLower(strings.TrimSpace(m.pickerSearch.Value())); query != "" {
+		for _, c := range m.categories {
+			if strings.Contains(strings.ToLower(c.Path), query) {
+				rows = append(rows, c)
+			}
+		}
+		return rows
+	}
+
+	// Categories are ordered depth first, so parents are visited first.
+	visible := make(map[int64]bool)
+	for _, c := range m.categories {
+		if c.Depth == 0 || (visible[c.ParentID] && m.pickerExpanded[c.ParentID]) {
+			visible[c.ID] = true
+			rows = append(rows, c)
+		}
+	}
+	return rows
+}
+
+func (m *model) hasSubcategories(id int64) bool {
+	for _, c := range m.categories {
+		if c.ParentID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *model) editCategoryName(action, name string) {
+	m.categoryAction = action
+	m.categoryInput.SetValue(name)
+	m.categoryInput.CursorEnd()
+	m.categoryInput.Focus()
+	m.stateDescription = "[enter] save [esc] cancel"
+	m.stateStatus = tui.StatusBarStateBlue
+	if action == "rename" && m.isConfiguredCategory(m.categories[m.categoryCursor].Path) {
+		m.stateDescription = fmt.Sprintf("%s is in the config and comes back on restart [enter] save [esc] cancel", name)
+		m.stateStatus = tui.StatusBarStateYellow
+	}
+	m.mode = modeCategoryName
+}
+
+// isConfiguredCategory reports whether the category at path is one of the
+// configured categories that are added back at startup, so renaming or
+// deleting it in the database alone does not last.
+func (m *model) isConfiguredCategory(path string) bool {
+	if m.cfg.CategorySource == "database" {
+		return false
+	}
+	for _, configured := range m.cfg.Categories {
+		names := store.SplitPath(configured)
+		for i := range names {
+			if strings.Join(names[:i+1], store.PathSeparator) == path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pickCategoryTarget lists where the transactions of the selected category
+// can go. Deleting may also leave them uncategorized.
+func (m *model) pickCategoryTarget(action string) {
+	selected := m.categories[m.categoryCursor]
+
+	m.categoryAction = action
+	m.categoryTargets = nil
+	switch action {
+	case "delete":
+		m.categoryTargets = append(m.categoryTargets, store.Category{Name: "(uncategorized)"})
+	case "parent":
+		m.categoryTargets = append(m.categoryTargets, store.Category{Name: "(top level)"})
+	}
+	for _, c := range m.categories {
+		if c.ID != selected.ID {
+			m.categoryTargets = append(m.categoryTargets, c)
+		}
+	}
+	m.targetCursor = 0
+
+	switch action {
+	case "merge":
+		m.stateDescription = fmt.Sprintf("Merge %s into... [enter] confirm [esc] cancel", selected.Name)
+	case "parent":
+		m.stateDescription = fmt.Sprintf("Move %s under... [enter] confirm [esc] cancel", selected.Name)
+	default:
+		m.stateDescription = fmt.Sprintf("Delete %s, moving %d transactions to... [enter] confirm", selected.Name, selected.Transactions)
+	}
+	// A merge deletes the selected category as well.
+	if action != "parent" && m.isConfiguredCategory(selected.Path) {
+		m.stateDescription += fmt.Sprintf(" (%s is in the config and comes back on restart)", selected.Name)
+	}
+	m.stateStatus = tui.StatusBarStateRed
+	m.mode = modeCategoryTarget
+}
+
+func (m *model) extractStatement(file string) tea.Cmd {
+	m.stateDescription = "Parsing statement..."
+	m.stateStatus = tui.StatusBarStateYellow
+	return db.ExtractStatement(m.service.ParserFor(file), m.categoryNames(), file)
+}
+
+// loadTransactions opens the transaction browser on the newest transactions.
+func (m *model) loadTransactions() tea.Cmd {
+	m.stateDescription = "Loading transactions..."
+	m.stateStatus = tui.StatusBarStateYellow
+	m.mode = modeLoading
+	m.loading = true
+	q := m.transactionFilter
+	q.Descending = true
+	q.Limit = transactionPage
+	return db.LoadTransactions(m.store, q, false)
+}
+
+// searchFilter matches the filters of the search box. A category name with
+// spaces is quoted, as in category:"eating out".
+var searchFilter = regexp.MustCompile(`(?i)\b(from|to|category):("[^"]*"|\S*)`)
+
+// parseSearch reads the search box: the words to look for, mixed with
+// from:, to: and category: filters as in "from:2024-01-01 category:dining
+// pizza". The category is matched by name or path, ignoring case.
+func (m *model) parseSearch(s string) (store.SearchQuery, error) {
+	var q store.SearchQuery
+	var err error
+	text := searchFilter.ReplaceAllStringFunc(s, func(filter string) string {
+		parts := searchFilter.FindStringSubmatch(filter)
+		value := strings.Trim(parts[2], `"`)
+		switch key := strings.ToLower(parts[1]); key {
+		case "from", "to":
+			if _, perr := time.Parse("2006-01-02", value); perr != nil && err == nil {
+				err = fmt.Errorf("invalid %s date %q, want YYYY-MM-DD", key, value)
+			}
+			if key == "from" {
+				q.StartDate = value
+			} else {
+				q.EndDate = value
+			}
+		case "category":
+			i := slices.IndexFunc(m.categories, func(c store.Category) bool {
+				return strings.EqualFold(c.Ref, value) || strings.EqualFold(c.Path, value)
+			})
+			if i < 0 && err == nil {
+				err = fmt.Errorf("unknown category %q", value)
+			} else if i >= 0 {
+				q.CategoryName = m.categories[i].Ref
+			}
+		}
+		return " "
+	})
+	if err != nil {
+		return store.SearchQuery{}, err
+	}
+	q.Text = strings.Join(strings.Fields(text), " ")
+	return q, nil
+}
+
+// statementCurrency is the currency of imported amounts whose statement
+// names none: that of the picked account, or the configured default.
+func (m *model) statementCurrency() string {
+	for _, a := range m.accounts {
+		if a.ID == m.extractedTx.Import.AccountID && a.Currency != "" {
+			return a.Currency
+		}
+	}
+	return m.cfg.Currency
+}
+
+// categoryNames lists the stored categories, which parsers and the
+// categorize picker offer, by the names transactions refer to them with.
+func (m *model) categoryNames() []string {
+	names := make([]string, len(m.categories))
+	for i, c := range m.categories {
+		names[i] = c.Ref
+	}
+	return names
+}
+
 func (m model) View() string {
 	doc := &strings.Builder{}
 
@@ -355,13 +1669,59 @@ func renderLists(doc *strings.Builder, m model) {
 		})
 	}
 
+	reviewing := len(m.review) > 0 && (m.mode == modeReview || m.mode == modeSplit || m.mode == modeSplitInput ||
+		(m.mode == modeTagInput && m.tagAction == "review"))
+
 	var leftList string
-	if m.mode == modeCategorize {
+	if reviewing {
+		item := m.review[m.reviewCursor]
+		txDetails := []tui.Item{
+			{Value: fmt.Sprintf("Date: %s", item.tx.Date)},
+			{Value: fmt.Sprintf("Desc: %s", item.tx.Description)},
+			{Value: fmt.Sprintf("Amount: %s", item.tx.Amount)},
+			{Value: fmt.Sprintf("Category: %s", item.tx.CategoryName)},
+		}
+		if len(item.tx.Tags) > 0 {
+			txDetails = append(txDetails, tui.Item{Value: fmt.Sprintf("Tags: %s", strings.Join(item.tx.Tags, ", "))})
+		}
+		if len(item.tx.Splits) > 0 {
+			txDetails = append(txDetails, tui.Item{Value: fmt.Sprintf("Split: %d parts", len(item.tx.Splits))})
+		}
+		if rule := item.rule; rule != nil {
+			txDetails = append(txDetails, tui.Item{Value: fmt.Sprintf("Rule: %s", rule.Name), Disabled: true})
+		}
+		if learned := item.learned; learned != nil {
+			txDetails = append(txDetails, tui.Item{Value: fmt.Sprintf("Learned: %s", learned.Pattern), Disabled: true})
+		}
+		if s := item.suggested; s != nil {
+			txDetails = append(txDetails, tui.Item{Value: fmt.Sprintf("Suggested: %.0f%% sure", s.Confidence*100), Disabled: true})
+		}
+		if dup := item.duplicate; dup != nil {
+			txDetails = append(txDetails,
+				tui.Item{Value: "Possible duplicate of:", Disabled: true},
+				tui.Item{Value: fmt.Sprintf("%s %s", dup.Date, dup.Amount), Disabled: true},
+				tui.Item{Value: dup.Description, Disabled: true},
+			)
+		}
+		leftList = tui.RenderListCommands(doc, &tui.ListProps{Items: txDetails, Selected: -1})
+	} else if m.mode == modeTransactions && len(m.transactions) > 0 {
+		tx := m.transactions[m.transactionCursor]
+		txDetails := []tui.Item{
+			{Value: fmt.Sprintf("Date: %s", tx.Date)},
+			{Value: fmt.Sprintf("Desc: %s", tx.Description)},
+			{Value: fmt.Sprintf("Amount: %s", tx.Amount)},
+			{Value: fmt.Sprintf("Category: %s", tx.CategoryName)},
+		}
+		if tx.Notes != "" {
+			txDetails = append(txDetails, tui.Item{Value: fmt.Sprintf("Notes: %s", tx.Notes)})
+		}
+		leftList = tui.RenderListCommands(doc, &tui.ListProps{Items: txDetails, Selected: -1})
+	} else if m.mode == modeCategorize {
 		currentTx := m.uncategorizedTx[m.uncategorizedCursor]
 		txDetails := []tui.Item{
 			{Value: fmt.Sprintf("Date: %s", currentTx.Date)},
 			{Value: fmt.Sprintf("Desc: %s", currentTx.Description)},
-			{Value: fmt.Sprintf("Amount: %.2f", currentTx.Amount)},
+			{Value: fmt.Sprintf("Amount: %s", currentTx.Amount)},
 		}
 		leftList = tui.RenderListCommands(doc, &tui.ListProps{Items: txDetails})
 	} else {
@@ -372,7 +1732,7 @@ func renderLists(doc *strings.Builder, m model) {
 	}
 
 	var rightList string
-	if m.mode == modeFilePicker {
+	if m.mode == modeFilePicker || m.mode == modeReimport {
 		var fileList []string
 		for i, f := range m.fileStatements {
 			if i == m.fileCursor {
@@ -384,14 +1744,200 @@ func renderLists(doc *strings.Builder, m model) {
 		rightList = tui.RenderListDisplay("Statements", fileList)
 	} else if m.mode == modeCategorize {
 		var catList []string
-		for i, c := range m.cfg.Categories {
+		searching := m.pickerSearch.Focused() || m.pickerSearch.Value() != ""
+		if searching {
+			catList = append(catList, m.pickerSearch.View())
+		}
+		rows := m.pickerRows()
+		start, end := visibleRange(m.txCursor, len(rows), 10)
+		for i := start; i < end; i++ {
+			c := rows[i]
+			label := c.Path
+			if !searching {
+				marker := " "
+				if m.hasSubcategories(c.ID) {
+					marker = "+"
+					if m.pickerExpanded[c.ID] {
+						marker = "-"
+					}
+				}
+				label = strings.Repeat("  ", c.Depth) + marker + c.Name
+			}
 			if i == m.txCursor {
-				catList = append(catList, fmt.Sprintf("[x] %s", c))
+				catList = append(catList, fmt.Sprintf("[x] %s", label))
 			} else {
-				catList = append(catList, fmt.Sprintf("[ ] %s", c))
+				catList = append(catList, fmt.Sprintf("[ ] %s", label))
 			}
 		}
 		rightList = tui.RenderListDisplay("Categories", catList)
+	} else if m.mode == modeReview {
+		var reviewList []string
+		start, end := visibleRange(m.reviewCursor, len(m.review), 10)
+		for i := start; i < end; i++ {
+			item := m.review[i]
+			mark := "[x]"
+			if item.drop {
+				mark = "[ ]"
+			}
+			switch {
+			case item.duplicate != nil:
+				mark += "!"
+			case item.rule != nil:
+				mark += "*"
+			case item.learned != nil:
+				mark += "~"
+			case item.suggested != nil:
+				mark += "?"
+			default:
+				mark += " "
+			}
+			line := fmt.Sprintf("%s %s %s", mark, item.tx.Description, item.tx.Amount)
+			if i == m.reviewCursor {
+				reviewList = append(reviewList, fmt.Sprintf("> %s", line))
+			} else {
+				reviewList = append(reviewList, fmt.Sprintf("  %s", line))
+			}
+		}
+		rightList = tui.RenderListDisplay(fmt.Sprintf("Review %d/%d", m.reviewCursor+1, len(m.review)), reviewList)
+	} else if m.mode == modeImports || m.mode == modeRollback {
+		var importList []string
+		for i, imp := range m.imports {
+			line := fmt.Sprintf("%s %s (%d)", imp.ImportedAt.Format("2006-01-02"), imp.FileName, imp.RowCount)
+			if i == m.importCursor {
+				importList = append(importList, fmt.Sprintf("> %s", line))
+			} else {
+				importList = append(importList, fmt.Sprintf("  %s", line))
+			}
+		}
+		rightList = tui.RenderListDisplay("Imports", importList)
+	} else if m.mode == modeMerchants {
+		var merchantList []string
+		start, end := visibleRange(m.merchantCursor, len(m.merchants), 10)
+		for i := start; i < end; i++ {
+			merchant := m.merchants[i]
+			line := fmt.Sprintf("%s -> %s (%d)", merchant.Pattern, merchant.CategoryName, merchant.Hits)
+			if i == m.merchantCursor {
+				merchantList = append(merchantList, fmt.Sprintf("> %s", line))
+			} else {
+				merchantList = append(merchantList, fmt.Sprintf("  %s", line))
+			}
+		}
+		rightList = tui.RenderListDisplay("Learned Categories", merchantList)
+	} else if m.mode == modeAccount || m.mode == modeAccountName {
+		var accountList []string
+		if m.mode == modeAccountName {
+			accountList = append(accountList, m.accountInput.View())
+		}
+		labels := make([]string, 0, len(m.accounts)+1)
+		for _, a := range m.accounts {
+			labels = append(labels, accountLabel(a))
+		}
+		labels = append(labels, "No account")
+		for i, label := range labels {
+			if i == m.accountCursor && m.mode == modeAccount {
+				accountList = append(accountList, fmt.Sprintf("> %s", label))
+			} else {
+				accountList = append(accountList, fmt.Sprintf("  %s", label))
+			}
+		}
+		rightList = tui.RenderListDisplay("Account", accountList)
+	} else if m.mode == modeSplit || m.mode == modeSplitInput {
+		var splitList []string
+		if m.mode == modeSplitInput {
+			splitList = append(splitList, m.splitInput.View())
+		}
+		for i, s := range m.splitDraft {
+			line := fmt.Sprintf("%s %s", s.CategoryName, s.Amount)
+			if i == m.splitCursor && m.mode == modeSplit {
+				splitList = append(splitList, fmt.Sprintf("> %s", line))
+			} else {
+				splitList = append(splitList, fmt.Sprintf("  %s", line))
+			}
+		}
+		tx := m.review[m.reviewCursor].tx
+		splitList = append(splitList, fmt.Sprintf("  Left: %s", store.SplitRemainder(tx.Amount, m.splitDraft)))
+		rightList = tui.RenderListDisplay(fmt.Sprintf("Split %s", tx.Amount), splitList)
+	} else if m.mode == modeTags {
+		var tagList []string
+		start, end := visibleRange(m.tagCursor, len(m.tags), 10)
+		for i := start; i < end; i++ {
+			t := m.tags[i]
+			line := fmt.Sprintf("%s (%d) %s", t.Name, t.Transactions, t.Total)
+			if i == m.tagCursor {
+				tagList = append(tagList, fmt.Sprintf("> %s", line))
+			} else {
+				tagList = append(tagList, fmt.Sprintf("  %s", line))
+			}
+		}
+		rightList = tui.RenderListDisplay("Tags", tagList)
+	} else if m.mode == modeTransactions {
+		var txList []string
+		if m.transactionSearch.Focused() || m.transactionSearch.Value() != "" {
+			txList = append(txList, m.transactionSearch.View())
+		}
+		start, end := visibleRange(m.transactionCursor, len(m.transactions), 10)
+		for i := start; i < end; i++ {
+			t := m.transactions[i]
+			line := fmt.Sprintf("%s %s %s", t.Date, t.Description, t.Amount)
+			if i == m.transactionCursor {
+				txList = append(txList, fmt.Sprintf("> %s", line))
+			} else {
+				txList = append(txList, fmt.Sprintf("  %s", line))
+			}
+		}
+		header := "Transactions"
+		if m.transactionsNext != "" {
+			header = fmt.Sprintf("Transactions (%d, more with [n])", len(m.transactions))
+		}
+		rightList = tui.RenderListDisplay(header, txList)
+	} else if m.mode == modeTagInput {
+		header := "Tags"
+		if m.tagAction == "rename" {
+			header = "Rename " + m.tags[m.tagCursor].Name
+		}
+		rightList = tui.RenderListDisplay(header, []string{m.tagInput.View()})
+	} else if m.mode == modeCategories {
+		var categoryList []string
+		start, end := visibleRange(m.categoryCursor, len(m.categories), 10)
+		for i := start; i < end; i++ {
+			c := m.categories[i]
+			line := fmt.Sprintf("%s%s (%d)", strings.Repeat("  ", c.Depth), c.Name, c.Transactions)
+			if i == m.categoryCursor {
+				categoryList = append(categoryList, fmt.Sprintf("> %s", line))
+			} else {
+				categoryList = append(categoryList, fmt.Sprintf("  %s", line))
+			}
+		}
+		rightList = tui.RenderListDisplay("Categories", categoryList)
+	} else if m.mode == modeCategoryName {
+		header := "New Category"
+		if m.categoryAction == "rename" {
+			header = "Rename " + m.categories[m.categoryCursor].Name
+		}
+		rightList = tui.RenderListDisplay(header, []string{m.categoryInput.View()})
+	} else if m.mode == modeCategoryTarget {
+		var targetList []string
+		start, end := visibleRange(m.targetCursor, len(m.categoryTargets), 10)
+		for i := start; i < end; i++ {
+			c := m.categoryTargets[i]
+			label := c.Path
+			if label == "" {
+				label = c.Name
+			}
+			if i == m.targetCursor {
+				targetList = append(targetList, fmt.Sprintf("> %s", label))
+			} else {
+				targetList = append(targetList, fmt.Sprintf("  %s", label))
+			}
+		}
+		header := "Merge Into"
+		switch m.categoryAction {
+		case "delete":
+			header = "Move Transactions To"
+		case "parent":
+			header = "Move Under"
+		}
+		rightList = tui.RenderListDisplay(header, targetList)
 	} else {
 		rightList = tui.RenderListDisplay(m.secondListHeader, m.secondListValues)
 	}
@@ -402,6 +1948,12 @@ func renderLists(doc *strings.Builder, m model) {
 	doc.WriteString("\n\n")
 }
 
+// visibleRange returns the window of at most size items around cursor.
+func visibleRange(cursor, n, size int) (int, int) {
+	start := max(0, min(cursor-size/2, n-size))
+	return start, min(n, start+size)
+}
+
 func shortenErr(err error, length int) string {
 	if len(err.Error()) < length {
 		return err.Error()
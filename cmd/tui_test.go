@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dylanewe/moni/internal/config"
+	"github.com/dylanewe/moni/internal/db"
+	"github.com/dylanewe/moni/internal/migrate"
+	"github.com/dylanewe/moni/internal/money"
+	"github.com/dylanewe/moni/internal/service"
+	"github.com/dylanewe/moni/internal/store"
+	"github.com/dylanewe/moni/internal/tui"
+)
+
+const statement = `Date,Description,Amount
+2024-03-01,ACME PAYROLL,2500.00
+2024-03-02,CORNER CAFE,-4.50
+2024-03-03,CITY TRAIN,-2.80
+`
+
+// newTestModel returns a model connected to an in-memory store, with
+// bank.csv waiting in the statements folder the TUI reads from.
+func newTestModel(t *testing.T) (model, store.Store) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, sub := range []string{"statements", "cmd"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "statements", "bank.csv"), []byte(statement), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(dir, "cmd"))
+
+	cfg := config.Config{
+		Banks: []config.BankProfile{{
+			Name:              "Bank",
+			DateColumn:        "Date",
+			DescriptionColumn: "Description",
+			AmountColumn:      "Amount",
+			DateFormat:        "2006-01-02",
+		}},
+	}
+	cfg.Classifier.ModelPath = filepath.Join(dir, "classifier.json")
+	svc, err := service.NewService(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := store.NewMemoryStore()
+	for _, name := range []string{"income", "dining", "groceries", "shopping", "subscriptions", "travel", "rent"} {
+		if err := s.Categories.Insert(context.Background(), &store.Category{Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	categories, err := s.Categories.GetAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := send(t, initModel(&cfg, &svc), db.DBConnectionMsg{Store: &s, Categories: categories})
+	return m, s
+}
+
+// send delivers msg to m and then runs the commands it returns until the
+// model settles, like the Bubble Tea event loop. Only the app's own messages
+// are fed back; spinner ticks, cursor blinks and quitting are dropped.
+func send(t *testing.T, m model, msgs ...tea.Msg) model {
+	t.Helper()
+
+	for len(msgs) > 0 {
+		msg := msgs[0]
+		msgs = msgs[1:]
+
+		updated, cmd := m.Update(msg)
+		m = updated.(model)
+		msgs = append(msgs, run(cmd)...)
+	}
+	return m
+}
+
+func run(cmd tea.Cmd) []tea.Msg {
+	if cmd == nil {
+		return nil
+	}
+
+	switch msg := cmd().(type) {
+	case nil:
+		return nil
+	case tea.BatchMsg:
+		var msgs []tea.Msg
+		for _, cmd := range msg {
+			msgs = append(msgs, run(cmd)...)
+		}
+		return msgs
+	default:
+		if !strings.HasPrefix(reflect.TypeOf(msg).PkgPath(), "github.com/dylanewe/moni/") {
+			return nil
+		}
+		return []tea.Msg{msg}
+	}
+}
+
+func keys(names ...string) []tea.Msg {
+	msgs := make([]tea.Msg, len(names))
+	for i, name := range names {
+		switch name {
+		case "enter":
+			msgs[i] = tea.KeyMsg{Type: tea.KeyEnter}
+		case "up":
+			msgs[i] = tea.KeyMsg{Type: tea.KeyUp}
+		case "down":
+			msgs[i] = tea.KeyMsg{Type: tea.KeyDown}
+		case "space":
+			msgs[i] = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}}
+		default:
+			msgs[i] = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(name)}
+		}
+	}
+	return msgs
+}
+
+// openStatement picks bank.csv from the Add Statement command, the second
+// in the list.
+func openStatement(t *testing.T, m model) model {
+	t.Helper()
+	m = send(t, m, keys("up", "up", "down", "enter")...)
+	if m.mode != modeFilePicker {
+		t.Fatalf("mode = %q after Add Statement, want %q: %s", m.mode, modeFilePicker, m.stateDescription)
+	}
+	return send(t, m, keys("enter")...)
+}
+
+func TestImportCategorizeAndSave(t *testing.T) {
+	m, s := newTestModel(t)
+	ctx := context.Background()
+	// Neither the CSV nor the new account names a currency.
+	m.cfg.Currency = "eur"
+
+	m = openStatement(t, m)
+	if m.mode != modeAccount {
+		t.Fatalf("mode = %q after picking the statement, want %q: %s", m.mode, modeAccount, m.stateDescription)
+	}
+
+	// Add an account for the statement and import into it.
+	m = send(t, m, keys("n", "Everyday 1234", "enter")...)
+	if len(m.accounts) != 1 || m.accountCursor != 0 {
+		t.Fatalf("accounts = %+v, cursor %d", m.accounts, m.accountCursor)
+	}
+	m = send(t, m, keys("enter")...)
+	if m.mode != modeReview || len(m.review) != 3 {
+		t.Fatalf("mode = %q with %d transactions to review: %s", m.mode, len(m.review), m.stateDescription)
+	}
+
+	// Drop the train ticket and categorize the rest through the search.
+	m = send(t, m, keys("down", "down", "space", "enter")...)
+	if m.mode != modeCategorize || len(m.uncategorizedTx) != 2 {
+		t.Fatalf("mode = %q with %d transactions to categorize", m.mode, len(m.uncategorizedTx))
+	}
+	m = send(t, m, keys("/", "income", "enter", "/", "dining", "enter")...)
+	if m.mode != modeSaving {
+		t.Fatalf("mode = %q after categorizing, want %q", m.mode, modeSaving)
+	}
+
+	m = send(t, m, keys("y")...)
+	if m.mode != modeDefault || !strings.Contains(m.stateDescription, "added 2 transactions") {
+		t.Fatalf("mode = %q after saving: %s", m.mode, m.stateDescription)
+	}
+
+	all, err := s.Transactions.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"ACME PAYROLL": "income", "CORNER CAFE": "dining"}
+	if len(all) != len(want) {
+		t.Fatalf("stored %d transactions, want %d", len(all), len(want))
+	}
+	for _, tx := range all {
+		if want[tx.Description] != tx.CategoryName {
+			t.Errorf("%s filed under %q, want %q", tx.Description, tx.CategoryName, want[tx.Description])
+		}
+		if tx.AccountID != m.accounts[0].ID {
+			t.Errorf("%s imported into account %d, want %d", tx.Description, tx.AccountID, m.accounts[0].ID)
+		}
+		if tx.Amount.Currency != "EUR" {
+			t.Errorf("%s saved in %q, want the configured EUR", tx.Description, tx.Amount.Currency)
+		}
+	}
+	// The currency of the account wins over the configured one.
+	m.accounts[0].Currency = "USD"
+	if got := m.statementCurrency(); got != "USD" {
+		t.Errorf("statement currency = %q, want the account's USD", got)
+	}
+
+	merchants, err := s.Merchants.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merchants) != 2 {
+		t.Errorf("learned %d categories, want 2", len(merchants))
+	}
+}
+
+func TestImportLearnedCategories(t *testing.T) {
+	m, s := newTestModel(t)
+	ctx := context.Background()
+	if err := s.Merchants.Remember(ctx, service.MerchantPattern("ACME PAYROLL"), "income"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rules.Insert(ctx, &store.Rule{Name: "transit", Pattern: "train", CategoryName: "travel"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// With no accounts the picker starts on "No account".
+	m = send(t, openStatement(t, m), keys("enter")...)
+	if m.mode != modeReview {
+		t.Fatalf("mode = %q, want %q: %s", m.mode, modeReview, m.stateDescription)
+	}
+	if m.review[0].learned == nil || m.review[2].rule == nil {
+		t.Errorf("review = %+v, want a learned category and a rule", m.review)
+	}
+
+	// Only the cafe is left to categorize.
+	m = send(t, m, keys("enter")...)
+	if len(m.uncategorizedTx) != 1 || m.uncategorizedTx[0].Description != "CORNER CAFE" {
+		t.Fatalf("uncategorized = %+v", m.uncategorizedTx)
+	}
+	m = send(t, m, keys("/", "dining", "enter", "y")...)
+
+	imports, err := s.Imports.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(imports) != 1 || imports[0].RowCount != 3 || imports[0].AccountID != 0 {
+		t.Fatalf("imports = %+v", imports)
+	}
+
+	// Picking the same file again warns before parsing it twice.
+	m = openStatement(t, m)
+	if m.mode != modeReimport {
+		t.Errorf("mode = %q on a second import, want %q", m.mode, modeReimport)
+	}
+}
+
+func TestImportEmptyStatement(t *testing.T) {
+	m, _ := newTestModel(t)
+	header := strings.SplitAfter(statement, "\n")[0]
+	if err := os.WriteFile(filepath.Join("..", "statements", "bank.csv"), []byte(header), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m = send(t, openStatement(t, m), keys("enter")...)
+	if m.mode != modeDefault || m.stateDescription != "No transactions found" {
+		t.Fatalf("mode = %q after importing an empty statement: %s", m.mode, m.stateDescription)
+	}
+	_ = m.View()
+
+	// The review shortcuts do nothing outside a review.
+	m = send(t, m, keys("space", "t", "s")...)
+	_ = m.View()
+}
+
+func TestQuitKeyStepsBack(t *testing.T) {
+	m, _ := newTestModel(t)
+
+	m = send(t, openStatement(t, m), keys("enter")...)
+	if m.mode != modeReview {
+		t.Fatalf("mode = %q, want %q", m.mode, modeReview)
+	}
+	steps := []mode{modeAccount, modeFilePicker, modeDefault}
+	for _, want := range steps {
+		updated, cmd := m.Update(keys("q")[0])
+		m = updated.(model)
+		if m.mode != want {
+			t.Fatalf("q went to %q, want %q", m.mode, want)
+		}
+		if cmd != nil {
+			if _, quit := cmd().(tea.QuitMsg); quit {
+				t.Fatalf("q quit in %q", want)
+			}
+		}
+	}
+	if len(m.extractedTx.Transactions) != 3 {
+		t.Errorf("going back lost the parsed statement")
+	}
+
+	// From the command list q quits.
+	if _, cmd := m.Update(keys("q")[0]); cmd == nil {
+		t.Error("q did not quit from the command list")
+	} else if _, quit := cmd().(tea.QuitMsg); !quit {
+		t.Error("q did not quit from the command list")
+	}
+}
+
+func TestConnectionStatus(t *testing.T) {
+	m, _ := newTestModel(t)
+	if m.stateDescription != "Connected to database" || m.stateStatus != tui.StatusBarStateGreen {
+		t.Errorf("status = %q", m.stateDescription)
+	}
+
+	m = send(t, m, db.DBConnectionMsg{
+		Store:      m.store,
+		Categories: m.categories,
+		Migrated:   make([]migrate.Migration, 2),
+		Added:      []string{"Food", "Food > Other"},
+		Unknown:    []string{"rent"},
+	})
+	want := "Connected to database, applied 2 migrations; added categories from config: Food, Food > Other; categories not in config: rent"
+	if m.stateDescription != want || m.stateStatus != tui.StatusBarStateYellow {
+		t.Errorf("status = %q, want %q", m.stateDescription, want)
+	}
+}
+
+func TestConfiguredCategoryWarnings(t *testing.T) {
+	m, _ := newTestModel(t)
+	m.cfg.Categories = []string{"dining", "Food > groceries"}
+
+	m = send(t, m, keys("down", "down", "enter")...)
+	if m.mode != modeCategories || m.categories[0].Name != "dining" {
+		t.Fatalf("mode = %q after Categories, want %q: %s", m.mode, modeCategories, m.stateDescription)
+	}
+	const warning = "dining is in the config and comes back on restart"
+	for _, key := range []string{"r", "x", "m"} {
+		m = send(t, m, keys(key)...)
+		if !strings.Contains(m.stateDescription, warning) {
+			t.Errorf("%s on a configured category: %s", key, m.stateDescription)
+		}
+		m = send(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	}
+
+	// Categories only in the database can go for good, like the top-level
+	// groceries that is not the configured Food > groceries.
+	m = send(t, m, keys("down", "r")...)
+	if m.categories[m.categoryCursor].Name != "groceries" || strings.Contains(m.stateDescription, "config") {
+		t.Errorf("renaming %s: %s", m.categories[m.categoryCursor].Name, m.stateDescription)
+	}
+	m = send(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+
+	// With the database as the source nothing comes back.
+	m.cfg.CategorySource = "database"
+	m.categoryCursor = 0
+	m = send(t, m, keys("x")...)
+	if strings.Contains(m.stateDescription, "config") {
+		t.Errorf("deleting with the database as the source: %s", m.stateDescription)
+	}
+}
+
+func TestBrowseAndSearchTransactions(t *testing.T) {
+	m, s := newTestModel(t)
+	transactions := []store.Transaction{
+		{Description: "AMAZON MKTPLACE", CategoryName: "shopping", Amount: amount(t, "-35.99"), Date: "2024-04-12", Notes: "gift for Sam"},
+		{Description: "Corner cafe", CategoryName: "dining", Amount: amount(t, "-4.50"), Date: "2024-04-13"},
+		{Description: "Amazon Prime", CategoryName: "subscriptions", Amount: amount(t, "-8.99"), Date: "2024-01-05"},
+	}
+	if err := s.Transactions.Insert(context.Background(), transactions); err != nil {
+		t.Fatal(err)
+	}
+
+	m = send(t, m, keys("down", "down", "down", "down", "down", "down", "enter")...)
+	if m.mode != modeTransactions {
+		t.Fatalf("mode = %q after Transactions, want %q: %s", m.mode, modeTransactions, m.stateDescription)
+	}
+	if got := descriptions(m.transactions); !slices.Equal(got, []string{"Corner cafe", "AMAZON MKTPLACE", "Amazon Prime"}) {
+		t.Errorf("browsing %v, want the newest first", got)
+	}
+
+	m = send(t, m, keys("/", "amzon", "enter")...)
+	if got := descriptions(m.transactions); !slices.Equal(got, []string{"AMAZON MKTPLACE", "Amazon Prime"}) {
+		t.Errorf("search found %v: %s", got, m.stateDescription)
+	}
+	m = send(t, m, keys("/", "gift", "enter")...)
+	if got := descriptions(m.transactions); !slices.Equal(got, []string{"AMAZON MKTPLACE"}) {
+		t.Errorf("search of notes found %v: %s", got, m.stateDescription)
+	}
+
+	// Dates and the category narrow the search, or the browser without
+	// words to search for.
+	m = send(t, m, keys("/", "from:2024-04-01 amazon", "enter")...)
+	if got := descriptions(m.transactions); !slices.Equal(got, []string{"AMAZON MKTPLACE"}) {
+		t.Errorf("search from April found %v: %s", got, m.stateDescription)
+	}
+	m = send(t, m, keys("/", "Category:Subscriptions to:2024-12-31", "enter")...)
+	if got := descriptions(m.transactions); !slices.Equal(got, []string{"Amazon Prime"}) || m.mode != modeTransactions {
+		t.Errorf("browsing subscriptions found %v: %s", got, m.stateDescription)
+	}
+	m = send(t, m, keys("/", "to:2023-12-31", "enter")...)
+	if len(m.transactions) != 0 || m.mode != modeTransactions || !strings.Contains(m.stateDescription, "No transactions match") {
+		t.Errorf("browsing 2023 found %v in %q: %s", descriptions(m.transactions), m.mode, m.stateDescription)
+	}
+	for input, want := range map[string]string{
+		"from:04/01/2024 amazon":     "invalid from date",
+		`category:"eating out" cafe`: `unknown category "eating out"`,
+	} {
+		m = send(t, m, keys("/", input, "enter")...)
+		if !m.transactionSearch.Focused() || !strings.Contains(m.stateDescription, want) {
+			t.Errorf("searching %q: %s", input, m.stateDescription)
+		}
+		m = send(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	}
+
+	// Escape clears the search, and then leaves the browser.
+	m = send(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.mode != modeTransactions || len(m.transactions) != 3 {
+		t.Errorf("mode = %q with %d transactions after clearing the search", m.mode, len(m.transactions))
+	}
+	m = send(t, m, tea.KeyMsg{Type: tea.KeyEsc})
+	if m.mode != modeDefault {
+		t.Errorf("mode = %q after leaving the browser", m.mode)
+	}
+}
+
+func amount(t *testing.T, s string) money.Money {
+	t.Helper()
+	m, err := money.Parse(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func descriptions(transactions []store.Transaction) []string {
+	var got []string
+	for _, t := range transactions {
+		got = append(got, t.Description)
+	}
+	return got
+}
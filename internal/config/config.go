@@ -1,22 +1,102 @@
 package config
 
-import "github.com/BurntSushi/toml"
+import (
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
 
 type Config struct {
-	LLM        LLMConfig `toml:"llm"`
-	DB         DBConfig  `toml:"db"`
-	Categories []string  `toml:"categories"`
+	LLM        LLMConfig        `toml:"llm"`
+	DB         DBConfig         `toml:"db"`
+	PDF        PDFConfig        `toml:"pdf"`
+	Dedupe     DedupeConfig     `toml:"dedupe"`
+	Classifier ClassifierConfig `toml:"classifier"`
+	Categories []string         `toml:"categories"`
+	// CategorySynonyms lists extra names per category, e.g.
+	// dining = ["restaurant", "food"].
+	CategorySynonyms map[string][]string `toml:"category_synonyms"`
+	// CategoryMatchThreshold is the minimum fuzzy-match similarity, between
+	// 0 and 1, for a parsed category to be mapped onto a known one.
+	CategoryMatchThreshold float64 `toml:"category_match_threshold"`
+	// CategorySource decides which category list wins at startup: with
+	// "config" (default) configured categories missing from the database are
+	// added, with "database" the stored categories are used as they are.
+	// With "config", a configured category renamed or deleted in the app is
+	// added back at the next startup.
+	CategorySource string        `toml:"category_source"`
+	Banks          []BankProfile `toml:"banks"`
+	// Currency is the ISO code, e.g. "EUR", of imported amounts when
+	// neither the statement nor its account names one. Transactions stored
+	// without a currency are moved into it at startup.
+	Currency string `toml:"currency"`
 }
 
+// LLMConfig points moni at OpenAI or any OpenAI-compatible server such as
+// llama.cpp, Ollama or vLLM. BaseURL is left empty for OpenAI itself.
 type LLMConfig struct {
-	APIKey string `toml:"api_key"`
-	Model  string `toml:"model"`
+	APIKey    string        `toml:"api_key"`
+	BaseURL   string        `toml:"base_url"`
+	Model     string        `toml:"model"`
+	Timeout   time.Duration `toml:"timeout"`
+	MaxTokens int64         `toml:"max_tokens"`
+	// ChunkSize caps the characters of statement text sent per request;
+	// longer statements are split on page boundaries.
+	ChunkSize   int `toml:"chunk_size"`
+	Concurrency int `toml:"concurrency"`
+	// RepairAttempts is how many times an invalid reply is sent back to
+	// the model with the validation errors before the chunk fails.
+	RepairAttempts *int `toml:"repair_attempts"`
 }
 
 type DBConfig struct {
 	Address string `toml:"address"`
 }
 
+// PDFConfig selects the text extraction backend for PDF statements:
+// "native" (default) or "pdftotext".
+type PDFConfig struct {
+	Extractor string `toml:"extractor"`
+}
+
+// DedupeConfig tunes how imported transactions are matched against the ones
+// already stored. Matches need the same amount, dates at most WindowDays
+// apart and a description similarity of at least DescriptionThreshold.
+type DedupeConfig struct {
+	WindowDays           *int    `toml:"window_days"`
+	DescriptionThreshold float64 `toml:"description_threshold"`
+}
+
+// ClassifierConfig controls the local categorizer trained on stored
+// transactions. Mode is "fallback" (default) to only fill in categories the
+// parser left empty, "replace" to override the parser, or "off".
+type ClassifierConfig struct {
+	ModelPath     string  `toml:"model_path"`
+	Mode          string  `toml:"mode"`
+	MinConfidence float64 `toml:"min_confidence"`
+	// Holdout is the share of the most recent history kept out of training
+	// to measure accuracy when retraining.
+	Holdout float64 `toml:"holdout"`
+}
+
+// BankProfile describes how to read the CSV export of a single bank.
+// Columns are referenced by their header name. Either AmountColumn or the
+// DebitColumn/CreditColumn pair must be set. The amount is then the credit
+// minus the debit, so a negative debit such as a reversal is money in.
+type BankProfile struct {
+	Name              string `toml:"name"`
+	FilePattern       string `toml:"file_pattern"`
+	Delimiter         string `toml:"delimiter"`
+	SkipRows          int    `toml:"skip_rows"`
+	DateColumn        string `toml:"date_column"`
+	DescriptionColumn string `toml:"description_column"`
+	AmountColumn      string `toml:"amount_column"`
+	DebitColumn       string `toml:"debit_column"`
+	CreditColumn      string `toml:"credit_column"`
+	DateFormat        string `toml:"date_format"`
+	DecimalSeparator  string `toml:"decimal_separator"`
+}
+
 func GetConfig(file string) (Config, error) {
 	var conf Config
 	if _, err := toml.DecodeFile(file, &conf); err != nil {
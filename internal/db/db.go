@@ -3,69 +3,576 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dylanewe/moni/internal/migrate"
 	"github.com/dylanewe/moni/internal/service"
 	"github.com/dylanewe/moni/internal/store"
+	"github.com/dylanewe/moni/internal/util"
 	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
 )
 
 type DBConnectionMsg struct {
 	DB    *sql.DB
 	Store *store.Store
-	Err   error
+	// Categories are the stored categories after syncing them with the
+	// configured ones.
+	Categories []store.Category
+	// Added lists the paths of configured categories that were missing
+	// from the database, Unknown those of stored categories that are not
+	// configured.
+	Added   []string
+	Unknown []string
+	// Migrated lists the schema migrations applied while connecting.
+	Migrated []migrate.Migration
+	Err      error
 }
 
-func Init(addr string, categories []string) tea.Cmd {
+// sqliteScheme prefixes the address of a SQLite database file, as in
+// "sqlite://moni.db". Any other address is handed to Postgres.
+const sqliteScheme = "sqlite://"
+
+// Dialect returns the SQL dialect of the database at addr.
+func Dialect(addr string) migrate.Dialect {
+	if strings.HasPrefix(addr, sqliteScheme) {
+		return migrate.SQLite
+	}
+	return migrate.Postgres
+}
+
+// Open connects to the database at addr and checks that it is reachable.
+func Open(ctx context.Context, addr string) (*sql.DB, error) {
+	driver, dsn := "pgx", addr
+	if Dialect(addr) == migrate.SQLite {
+		// Foreign keys are off by default in SQLite, and cascading deletes
+		// rely on them.
+		driver = "sqlite"
+		dsn = "file:" + strings.TrimPrefix(addr, sqliteScheme) + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite" {
+		// SQLite allows one writer at a time, so a single connection keeps
+		// transactions from failing with "database is locked".
+		db.SetMaxOpenConns(1)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed pinging db: %v", err)
+	}
+
+	return db, nil
+}
+
+// Migrate applies the pending schema migrations of the database at addr.
+func Migrate(ctx context.Context, db *sql.DB, addr string) ([]migrate.Migration, error) {
+	m, err := migrate.New(db, Dialect(addr))
+	if err != nil {
+		return nil, err
+	}
+	migrated, err := m.Up(ctx)
+	if err != nil {
+		return migrated, fmt.Errorf("failed to migrate database: %v", err)
+	}
+	return migrated, nil
+}
+
+// Init connects to the database, migrates it and syncs the categories with
+// the configured ones, which may be nested as in "Food > Dining". When
+// source is "database" the configured categories are only used to seed an
+// empty database. Transactions stored without a currency are moved into
+// currency unless it is empty.
+func Init(addr string, categories []string, source, currency string) tea.Cmd {
 	return func() tea.Msg {
 		ctx := context.TODO()
-		db, err := sql.Open("pgx", addr)
+		db, err := Open(ctx, addr)
 		if err != nil {
 			return DBConnectionMsg{Err: err}
 		}
-		if err = db.PingContext(ctx); err != nil {
-			return DBConnectionMsg{Err: fmt.Errorf("failed pinging db: %v", err)}
+
+		migrated, err := Migrate(ctx, db, addr)
+		if err != nil {
+			db.Close()
+			return DBConnectionMsg{Err: err}
 		}
 
-		store := store.NewStore(db)
+		s := store.NewStore(db)
 
-		return DBConnectionMsg{
-			DB:    db,
-			Store: &store,
-			Err:   nil,
+		msg := DBConnectionMsg{DB: db, Store: &s, Migrated: migrated}
+		if currency != "" {
+			if _, err := s.Transactions.SetMissingCurrency(ctx, currency); err != nil {
+				return DBConnectionMsg{Err: fmt.Errorf("failed to set missing currencies: %v", err)}
+			}
+		}
+		msg.Categories, err = s.Categories.GetAll(ctx)
+		if err != nil {
+			return DBConnectionMsg{Err: fmt.Errorf("failed to load categories: %v", err)}
+		}
+		if source == "database" && len(msg.Categories) > 0 {
+			return msg
+		}
+
+		// Configured categories may be paths such as "Food > Dining"; each
+		// name is looked up under its parent and missing ones are created
+		// along the way. Names are unique among siblings only, so
+		// "Food > Other" and "Housing > Other" are two categories.
+		stored := make(map[string]store.Category, len(msg.Categories))
+		for _, c := range msg.Categories {
+			stored[c.Path] = c
+		}
+		configured := make(map[string]bool)
+		for _, path := range categories {
+			var parent int64
+			names := store.SplitPath(path)
+			for i, name := range names {
+				path := strings.Join(names[:i+1], store.PathSeparator)
+				configured[path] = true
+				c, ok := stored[path]
+				if !ok {
+					c = store.Category{Name: name, ParentID: parent, Path: path}
+					if err := s.Categories.Insert(ctx, &c); err != nil {
+						return DBConnectionMsg{Err: fmt.Errorf("failed to add category %s: %v", path, err)}
+					}
+					stored[path] = c
+					msg.Added = append(msg.Added, path)
+				}
+				parent = c.ID
+			}
+		}
+		for path := range stored {
+			if !configured[path] {
+				msg.Unknown = append(msg.Unknown, path)
+			}
 		}
+		slices.Sort(msg.Unknown)
+
+		if len(msg.Added) > 0 {
+			msg.Categories, err = s.Categories.GetAll(ctx)
+			if err != nil {
+				return DBConnectionMsg{Err: fmt.Errorf("failed to load categories: %v", err)}
+			}
+		}
+
+		return msg
+	}
+}
+
+// CheckStatementMsg lists earlier imports of the same file, matched by
+// content hash, so the user can be warned before paying for a second parse.
+type CheckStatementMsg struct {
+	File     string
+	Previous []store.Import
+	Err      error
+}
+
+func CheckStatement(s *store.Store, file string) tea.Cmd {
+	return func() tea.Msg {
+		hash, err := util.HashFile(file)
+		if err != nil {
+			return CheckStatementMsg{Err: fmt.Errorf("failed to read statement: %v", err)}
+		}
+
+		previous, err := s.Imports.FindByHash(context.TODO(), hash)
+		if err != nil {
+			return CheckStatementMsg{Err: fmt.Errorf("failed to check import history: %v", err)}
+		}
+
+		return CheckStatementMsg{File: file, Previous: previous}
 	}
 }
 
 type ExtractStatementMsg struct {
+	File string
+	// Import describes the parsed file; it is saved with the transactions.
+	Import       *store.Import
 	Transactions []store.Transaction
-	Err          error
+	// Partial is set when some chunks of the statement could not be parsed
+	// but the rest of the transactions are usable.
+	Partial *service.ChunkErrors
+	Err     error
 }
 
-func ExtractStatement(llmParser service.StatementParser, cat []string, file string) tea.Cmd {
+func ExtractStatement(parser service.StatementParser, cat []string, file string) tea.Cmd {
 	return func() tea.Msg {
-		tx, err := llmParser.ParseStatement(context.TODO(), cat, file)
+		hash, err := util.HashFile(file)
+		if err != nil {
+			return ExtractStatementMsg{Err: fmt.Errorf("failed to read statement: %v", err)}
+		}
+
+		imp := &store.Import{
+			FileName:    filepath.Base(file),
+			ContentHash: hash,
+			Parser:      parser.Name(),
+		}
+		if p, ok := parser.(interface{ Model() string }); ok {
+			imp.Model = p.Model()
+		}
+
+		tx, err := parser.ParseStatement(context.TODO(), cat, file)
+		var partial *service.ChunkErrors
+		if errors.As(err, &partial) && len(tx) > 0 {
+			return ExtractStatementMsg{File: file, Import: imp, Transactions: tx, Partial: partial}
+		}
 		if err != nil {
 			return ExtractStatementMsg{Err: fmt.Errorf("failed to extract transactions: %v", err)}
 		}
 
 		return ExtractStatementMsg{
+			File:         file,
+			Import:       imp,
 			Transactions: tx,
 			Err:          nil,
 		}
 	}
 }
 
+// DetectAccountMsg lists the stored accounts and the index of the one the
+// statement seems to belong to, or -1.
+type DetectAccountMsg struct {
+	Accounts []store.Account
+	Detected int
+	Err      error
+}
+
+func DetectAccount(s *store.Store, svc *service.Service, file string, tx []store.Transaction) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.TODO()
+		accounts, err := s.Accounts.GetAll(ctx)
+		if err != nil {
+			return DetectAccountMsg{Err: fmt.Errorf("failed to load accounts: %v", err)}
+		}
+
+		msg := DetectAccountMsg{Accounts: accounts, Detected: -1}
+		if len(accounts) == 0 {
+			return msg
+		}
+
+		// Detection is best effort, a statement without readable text is
+		// matched on its file name and transactions alone.
+		text, _ := svc.StatementText(ctx, file)
+		if acc, ok := service.DetectAccount(accounts, file, text, tx); ok {
+			msg.Detected = slices.IndexFunc(accounts, func(a store.Account) bool { return a.ID == acc.ID })
+		}
+		return msg
+	}
+}
+
+type AddAccountMsg struct {
+	Account store.Account
+	Err     error
+}
+
+func AddAccount(s *store.Store, acc store.Account) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Accounts.Insert(context.TODO(), &acc); err != nil {
+			return AddAccountMsg{Err: fmt.Errorf("failed to add account: %v", err)}
+		}
+		return AddAccountMsg{Account: acc}
+	}
+}
+
+// ReviewMsg carries what is known about each parsed transaction before the
+// user reviews it, keyed by index: the category rule that matched it, the
+// category learned from earlier manual choices, the local classifier's
+// suggestion and the stored transaction it likely duplicates.
+type ReviewMsg struct {
+	Rules      map[int]store.Rule
+	Learned    map[int]store.Merchant
+	Suggested  map[int]service.Suggestion
+	Duplicates map[int]store.Transaction
+	Err        error
+}
+
+func PrepareReview(s *store.Store, svc *service.Service, tx []store.Transaction) tea.Cmd {
+	return func() tea.Msg {
+		ctx := context.TODO()
+
+		rules, err := s.Rules.GetAll(ctx)
+		if err != nil {
+			return ReviewMsg{Err: fmt.Errorf("failed to load rules: %v", err)}
+		}
+		engine, err := service.NewRuleEngine(rules)
+		if err != nil {
+			return ReviewMsg{Err: err}
+		}
+
+		merchants, err := s.Merchants.GetAll(ctx)
+		if err != nil {
+			return ReviewMsg{Err: fmt.Errorf("failed to load learned categories: %v", err)}
+		}
+		memory := service.NewMerchantMemory(merchants)
+
+		suggestions, err := svc.Classifier.Suggest(tx)
+		if err != nil {
+			return ReviewMsg{Err: err}
+		}
+
+		msg := ReviewMsg{
+			Rules:     engine.MatchAll(tx),
+			Learned:   make(map[int]store.Merchant),
+			Suggested: make(map[int]service.Suggestion),
+		}
+		for i := range tx {
+			if _, ok := msg.Rules[i]; ok {
+				continue
+			}
+			if merchant, ok := memory.Lookup(tx[i]); ok {
+				msg.Learned[i] = merchant
+				continue
+			}
+			if s, ok := suggestions[i]; ok {
+				msg.Suggested[i] = s
+			}
+		}
+
+		finder := svc.Duplicates
+		start, end, ok := finder.Range(tx)
+		if !ok {
+			return msg
+		}
+
+		existing, err := s.Transactions.GetByDate(ctx, start, end)
+		if err != nil {
+			return ReviewMsg{Err: fmt.Errorf("failed to check duplicates: %v", err)}
+		}
+		msg.Duplicates = finder.Find(tx, existing)
+
+		return msg
+	}
+}
+
 type AddStatementMsg struct {
-	Err error
+	Import *store.Import
+	Err    error
 }
 
-func AddStatement(txStore *store.Store, tx []store.Transaction) tea.Cmd {
+// AddStatement saves the import and its transactions, then remembers the
+// categories the user picked by hand, keyed by merchant pattern.
+// Transactions whose statement named no currency are saved in currency.
+func AddStatement(txStore *store.Store, imp *store.Import, tx []store.Transaction, learned map[string]string, currency string) tea.Cmd {
 	return func() tea.Msg {
-		if err := txStore.Transactions.Insert(context.TODO(), tx); err != nil {
+		ctx := context.TODO()
+		for i := range tx {
+			if tx[i].Amount.Currency == "" {
+				tx[i].Amount = tx[i].Amount.In(currency)
+			}
+		}
+		if err := txStore.Imports.Create(ctx, imp, tx); err != nil {
 			return AddStatementMsg{Err: fmt.Errorf("failed to insert transactions: %v", err)}
 		}
-		return AddStatementMsg{Err: nil}
+		for pattern, category := range learned {
+			if err := txStore.Merchants.Remember(ctx, pattern, category); err != nil {
+				return AddStatementMsg{Import: imp, Err: fmt.Errorf("transactions saved, but failed to remember categories: %v", err)}
+			}
+		}
+		return AddStatementMsg{Import: imp, Err: nil}
+	}
+}
+
+type LoadMerchantsMsg struct {
+	Merchants []store.Merchant
+	Err       error
+}
+
+func LoadMerchants(s *store.Store) tea.Cmd {
+	return func() tea.Msg {
+		merchants, err := s.Merchants.GetAll(context.TODO())
+		if err != nil {
+			return LoadMerchantsMsg{Err: fmt.Errorf("failed to load learned categories: %v", err)}
+		}
+		return LoadMerchantsMsg{Merchants: merchants}
+	}
+}
+
+type ForgetMerchantMsg struct {
+	Err error
+}
+
+func ForgetMerchant(s *store.Store, id int64) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Merchants.Forget(context.TODO(), id); err != nil {
+			return ForgetMerchantMsg{Err: fmt.Errorf("failed to forget category: %v", err)}
+		}
+		return ForgetMerchantMsg{}
+	}
+}
+
+type LoadImportsMsg struct {
+	Imports []store.Import
+	Err     error
+}
+
+func LoadImports(s *store.Store) tea.Cmd {
+	return func() tea.Msg {
+		imports, err := s.Imports.GetAll(context.TODO())
+		if err != nil {
+			return LoadImportsMsg{Err: fmt.Errorf("failed to load imports: %v", err)}
+		}
+		return LoadImportsMsg{Imports: imports}
+	}
+}
+
+type DeleteImportMsg struct {
+	Err error
+}
+
+func DeleteImport(s *store.Store, id int64) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Imports.Delete(context.TODO(), id); err != nil {
+			return DeleteImportMsg{Err: fmt.Errorf("failed to delete import: %v", err)}
+		}
+		return DeleteImportMsg{}
+	}
+}
+
+type LoadCategoriesMsg struct {
+	Categories []store.Category
+	Err        error
+}
+
+func LoadCategories(s *store.Store) tea.Cmd {
+	return func() tea.Msg {
+		categories, err := s.Categories.GetAll(context.TODO())
+		if err != nil {
+			return LoadCategoriesMsg{Err: fmt.Errorf("failed to load categories: %v", err)}
+		}
+		return LoadCategoriesMsg{Categories: categories}
+	}
+}
+
+// UpdateCategoryMsg reports the outcome of adding, renaming, merging or
+// deleting a category.
+type UpdateCategoryMsg struct {
+	Err error
+}
+
+func AddCategory(s *store.Store, name string) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Categories.Insert(context.TODO(), &store.Category{Name: name}); err != nil {
+			return UpdateCategoryMsg{Err: fmt.Errorf("failed to add category: %v", err)}
+		}
+		return UpdateCategoryMsg{}
+	}
+}
+
+func RenameCategory(s *store.Store, id int64, name string) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Categories.Rename(context.TODO(), id, name); err != nil {
+			return UpdateCategoryMsg{Err: fmt.Errorf("failed to rename category: %v", err)}
+		}
+		return UpdateCategoryMsg{}
+	}
+}
+
+// SetCategoryParent moves a category under parentID, or to the top level
+// when parentID is 0.
+func SetCategoryParent(s *store.Store, id, parentID int64) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Categories.SetParent(context.TODO(), id, parentID); err != nil {
+			return UpdateCategoryMsg{Err: fmt.Errorf("failed to move category: %v", err)}
+		}
+		return UpdateCategoryMsg{}
+	}
+}
+
+func MergeCategory(s *store.Store, from, into int64) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Categories.Merge(context.TODO(), from, into); err != nil {
+			return UpdateCategoryMsg{Err: fmt.Errorf("failed to merge categories: %v", err)}
+		}
+		return UpdateCategoryMsg{}
+	}
+}
+
+// DeleteCategory deletes a category, moving its transactions to moveTo or
+// leaving them uncategorized when moveTo is 0.
+func DeleteCategory(s *store.Store, id, moveTo int64) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Categories.Delete(context.TODO(), id, moveTo); err != nil {
+			return UpdateCategoryMsg{Err: fmt.Errorf("failed to delete category: %v", err)}
+		}
+		return UpdateCategoryMsg{}
+	}
+}
+
+type LoadTagsMsg struct {
+	Tags []store.Tag
+	Err  error
+}
+
+func LoadTags(s *store.Store) tea.Cmd {
+	return func() tea.Msg {
+		tags, err := s.Tags.GetAll(context.TODO())
+		if err != nil {
+			return LoadTagsMsg{Err: fmt.Errorf("failed to load tags: %v", err)}
+		}
+		return LoadTagsMsg{Tags: tags}
+	}
+}
+
+// UpdateTagMsg reports the outcome of renaming or deleting a tag.
+type UpdateTagMsg struct {
+	Err error
+}
+
+func RenameTag(s *store.Store, id int64, name string) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Tags.Rename(context.TODO(), id, name); err != nil {
+			return UpdateTagMsg{Err: fmt.Errorf("failed to rename tag: %v", err)}
+		}
+		return UpdateTagMsg{}
+	}
+}
+
+func DeleteTag(s *store.Store, id int64) tea.Cmd {
+	return func() tea.Msg {
+		if err := s.Tags.Delete(context.TODO(), id); err != nil {
+			return UpdateTagMsg{Err: fmt.Errorf("failed to delete tag: %v", err)}
+		}
+		return UpdateTagMsg{}
+	}
+}
+
+type LoadTransactionsMsg struct {
+	Page store.TransactionPage
+	// More is set when Page follows the transactions already loaded.
+	More bool
+	Err  error
+}
+
+// LoadTransactions lists a page of transactions. more marks a page that
+// continues the previous one.
+func LoadTransactions(s *store.Store, q store.TransactionQuery, more bool) tea.Cmd {
+	return func() tea.Msg {
+		page, err := s.Transactions.List(context.TODO(), q)
+		if err != nil {
+			return LoadTransactionsMsg{Err: fmt.Errorf("failed to load transactions: %v", err)}
+		}
+		return LoadTransactionsMsg{Page: page, More: more}
+	}
+}
+
+type SearchTransactionsMsg struct {
+	Query   store.SearchQuery
+	Results []store.SearchResult
+	Err     error
+}
+
+func SearchTransactions(s *store.Store, q store.SearchQuery) tea.Cmd {
+	return func() tea.Msg {
+		results, err := s.Transactions.Search(context.TODO(), q)
+		if err != nil {
+			return SearchTransactionsMsg{Query: q, Err: fmt.Errorf("failed to search transactions: %v", err)}
+		}
+		return SearchTransactionsMsg{Query: q, Results: results}
 	}
 }
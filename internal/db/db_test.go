@@ -0,0 +1,64 @@
+package db_test
+
+import (
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/dylanewe/moni/internal/db"
+	"github.com/dylanewe/moni/internal/store"
+)
+
+func TestInitCategoryPaths(t *testing.T) {
+	addr := "sqlite://" + filepath.Join(t.TempDir(), "moni.db")
+	connect := func(categories ...string) db.DBConnectionMsg {
+		t.Helper()
+		msg := db.Init(addr, categories, "config", "")().(db.DBConnectionMsg)
+		if msg.Err != nil {
+			t.Fatal(msg.Err)
+		}
+		t.Cleanup(func() { msg.DB.Close() })
+		return msg
+	}
+	paths := func(categories []store.Category) []string {
+		var paths []string
+		for _, c := range categories {
+			paths = append(paths, c.Path)
+		}
+		return paths
+	}
+
+	msg := connect("Food > Dining", "Food > Groceries", "Leisure")
+	want := []string{"Food", "Food > Dining", "Food > Groceries", "Leisure"}
+	if got := paths(msg.Categories); !slices.Equal(got, want) {
+		t.Errorf("categories = %q, want %q", got, want)
+	}
+	if !slices.Equal(msg.Added, want) {
+		t.Errorf("added = %q, want %q", msg.Added, want)
+	}
+
+	// Names only need to be unique among siblings, so Dining is added under
+	// Leisure as well.
+	msg = connect("Food > Dining", "Leisure > Dining > Cafes", "Food > Groceries", "Leisure")
+	want = []string{"Food", "Food > Dining", "Food > Groceries", "Leisure", "Leisure > Dining", "Leisure > Dining > Cafes"}
+	if got := paths(msg.Categories); !slices.Equal(got, want) {
+		t.Errorf("categories = %q, want %q", got, want)
+	}
+	if want := []string{"Leisure > Dining", "Leisure > Dining > Cafes"}; !slices.Equal(msg.Added, want) || len(msg.Unknown) != 0 {
+		t.Errorf("added %q, unknown %q", msg.Added, msg.Unknown)
+	}
+	for _, c := range msg.Categories {
+		if c.Name == "Dining" && c.Ref != c.Path {
+			t.Errorf("%s is referred to as %q, want its path", c.Path, c.Ref)
+		}
+	}
+
+	// A top-level name is not the subcategory of the same name.
+	msg = connect("Food", "Groceries")
+	if want := []string{"Groceries"}; !slices.Equal(msg.Added, want) {
+		t.Errorf("added = %q, want %q", msg.Added, want)
+	}
+	if want := []string{"Food > Dining", "Food > Groceries", "Leisure", "Leisure > Dining", "Leisure > Dining > Cafes"}; !slices.Equal(msg.Unknown, want) {
+		t.Errorf("unknown = %q, want %q", msg.Unknown, want)
+	}
+}
@@ -0,0 +1,400 @@
+package migrate_test
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dylanewe/moni/internal/db"
+	"github.com/dylanewe/moni/internal/migrate"
+)
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	addr := "sqlite://" + filepath.Join(t.TempDir(), "moni.db")
+	conn, err := db.Open(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m, err := migrate.New(conn, db.Dialect(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := m.Up(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) == 0 || len(applied) != len(status) {
+		t.Fatalf("applied %d of %d migrations", len(applied), len(status))
+	}
+	if again, err := m.Up(ctx); err != nil || len(again) != 0 {
+		t.Errorf("second Up applied %d migrations: %v", len(again), err)
+	}
+
+	// Everything but the baseline rolls back.
+	rolledBack, err := m.Down(ctx, len(status))
+	if err == nil {
+		t.Error("Down rolled back the baseline")
+	}
+	if len(rolledBack) != len(status)-1 || rolledBack[0].Version != status[len(status)-1].Version {
+		t.Errorf("Down rolled back %v", rolledBack)
+	}
+	status, err = m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status[0].AppliedAt.IsZero() {
+		t.Error("baseline is no longer applied")
+	}
+}
+
+// TestBaselineSeedsNothing checks that a fresh database starts without
+// categories; db.Init adds the configured ones.
+func TestBaselineSeedsNothing(t *testing.T) {
+	ctx := context.Background()
+	addr := "sqlite://" + filepath.Join(t.TempDir(), "moni.db")
+	conn, err := db.Open(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err := db.Migrate(ctx, conn, addr); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories`).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("fresh database has %d categories", n)
+	}
+}
+
+// TestMoneyCents checks that amounts written before 0002 are converted to
+// cents and back.
+func TestMoneyCents(t *testing.T) {
+	ctx := context.Background()
+	addr := "sqlite://" + filepath.Join(t.TempDir(), "moni.db")
+	conn, err := db.Open(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m, err := migrate.New(conn, db.Dialect(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Roll back to just before 0002.
+	later := 0
+	for _, s := range status {
+		if s.Version >= 2 {
+			later++
+		}
+	}
+	if _, err := m.Down(ctx, later); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		`INSERT INTO categories (id, name) VALUES (1, 'dining')`,
+		`INSERT INTO transactions (description, category_id, amount, date) VALUES ('Lunch', 1, 12.34, '2024-01-01')`,
+	} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var cents int64
+	if err := conn.QueryRowContext(ctx, `SELECT amount FROM transactions`).Scan(&cents); err != nil {
+		t.Fatal(err)
+	}
+	if cents != 1234 {
+		t.Errorf("12.34 migrated to %d cents, want 1234", cents)
+	}
+
+	if _, err := m.Down(ctx, later); err != nil {
+		t.Fatal(err)
+	}
+	var amount float64
+	if err := conn.QueryRowContext(ctx, `SELECT amount FROM transactions`).Scan(&amount); err != nil {
+		t.Fatal(err)
+	}
+	if amount != 12.34 {
+		t.Errorf("rolled back to %v, want 12.34", amount)
+	}
+}
+
+// TestRuleAccounts checks that rules limited to an account by name are tied
+// to that account, or to a new one when no account has the name.
+func TestRuleAccounts(t *testing.T) {
+	ctx := context.Background()
+	addr := "sqlite://" + filepath.Join(t.TempDir(), "moni.db")
+	conn, err := db.Open(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m, err := migrate.New(conn, db.Dialect(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Roll back to just before 0005.
+	later := 0
+	for _, s := range status {
+		if s.Version >= 5 {
+			later++
+		}
+	}
+	if _, err := m.Down(ctx, later); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		`INSERT INTO categories (id, name) VALUES (1, 'fees')`,
+		`INSERT INTO accounts (id, name) VALUES (1, 'Checking')`,
+		`INSERT INTO category_rules (name, pattern, account, category_id) VALUES ('a', 'fee', 'checking', 1)`,
+		`INSERT INTO category_rules (name, pattern, account, category_id) VALUES ('b', 'fee', 'Bank', 1)`,
+		`INSERT INTO category_rules (name, pattern, account, category_id) VALUES ('c', 'fee', 'BANK', 1)`,
+		`INSERT INTO category_rules (name, pattern, category_id) VALUES ('d', 'fee', 1)`,
+	} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := conn.QueryContext(ctx, `
+		SELECT r.name, COALESCE(a.name, '')
+		FROM category_rules r
+		LEFT JOIN accounts a ON a.id = r.account_id
+		ORDER BY r.name`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	got := make(map[string]string)
+	for rows.Next() {
+		var rule, account string
+		if err := rows.Scan(&rule, &account); err != nil {
+			t.Fatal(err)
+		}
+		got[rule] = account
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a": "Checking", "b": "BANK", "c": "BANK", "d": ""}
+	for rule, account := range want {
+		if got[rule] != account {
+			t.Errorf("rule %s has account %q, want %q", rule, got[rule], account)
+		}
+	}
+
+	if _, err := m.Down(ctx, later); err != nil {
+		t.Fatal(err)
+	}
+	var account string
+	if err := conn.QueryRowContext(ctx, `SELECT account FROM category_rules WHERE name = 'a'`).Scan(&account); err != nil {
+		t.Fatal(err)
+	}
+	if account != "Checking" {
+		t.Errorf("rolled back to account %q, want Checking", account)
+	}
+}
+
+// TestCategoryNamesPerParent checks that rebuilding the categories table
+// keeps the transactions filed under them, and that names then only need to
+// be unique among siblings.
+func TestCategoryNamesPerParent(t *testing.T) {
+	ctx := context.Background()
+	addr := "sqlite://" + filepath.Join(t.TempDir(), "moni.db")
+	conn, err := db.Open(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m, err := migrate.New(conn, db.Dialect(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Roll back to just before 0007.
+	later := 0
+	for _, s := range status {
+		if s.Version >= 7 {
+			later++
+		}
+	}
+	if _, err := m.Down(ctx, later); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		`INSERT INTO categories (id, name) VALUES (1, 'Food')`,
+		`INSERT INTO categories (id, name, parent_id) VALUES (2, 'Other', 1)`,
+		`INSERT INTO categories (id, name) VALUES (3, 'Housing')`,
+		`INSERT INTO transactions (description, category_id, amount, date) VALUES ('Snack', 2, -250, '2024-01-01')`,
+	} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var categoryID int64
+	if err := conn.QueryRowContext(ctx, `SELECT category_id FROM transactions`).Scan(&categoryID); err != nil {
+		t.Fatal(err)
+	}
+	if categoryID != 2 {
+		t.Errorf("transaction is filed under %d, want 2", categoryID)
+	}
+
+	if _, err := conn.ExecContext(ctx, `INSERT INTO categories (id, name, parent_id) VALUES (4, 'Other', 3)`); err != nil {
+		t.Fatalf("second Other under another parent: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, `INSERT INTO categories (name, parent_id) VALUES ('Other', 3)`); err == nil {
+		t.Error("inserted a second Other under Housing")
+	}
+	if _, err := conn.ExecContext(ctx, `INSERT INTO categories (name) VALUES ('Food')`); err == nil {
+		t.Error("inserted a second top-level Food")
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT id, path, ref FROM category_paths ORDER BY id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var id int64
+		var path, ref string
+		if err := rows.Scan(&id, &path, &ref); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, fmt.Sprintf("%d %s (%s)", id, path, ref))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"1 Food (Food)", "2 Food > Other (Food > Other)", "3 Housing (Housing)", "4 Housing > Other (Housing > Other)"}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("category paths = %v, want %v", got, want)
+	}
+
+	// Foreign keys are back on after the migration.
+	if _, err := conn.ExecContext(ctx, `DELETE FROM categories WHERE id = 4`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(ctx, `INSERT INTO transactions (description, category_id, amount, date) VALUES ('x', 99, 0, '2024-01-01')`); err == nil {
+		t.Error("inserted a transaction in a missing category")
+	}
+}
+
+// TestTransactionCurrency checks that transactions stored without a
+// currency take the one of their account.
+func TestTransactionCurrency(t *testing.T) {
+	ctx := context.Background()
+	addr := "sqlite://" + filepath.Join(t.TempDir(), "moni.db")
+	conn, err := db.Open(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	m, err := migrate.New(conn, db.Dialect(addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	status, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Roll back to just before 0008.
+	later := 0
+	for _, s := range status {
+		if s.Version >= 8 {
+			later++
+		}
+	}
+	if _, err := m.Down(ctx, later); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		`INSERT INTO accounts (id, name, currency) VALUES (1, 'Euro', 'EUR')`,
+		`INSERT INTO accounts (id, name) VALUES (2, 'Unknown')`,
+		`INSERT INTO transactions (description, amount, date, account_id) VALUES ('a', -100, '2024-01-01', 1)`,
+		`INSERT INTO transactions (description, amount, currency, date, account_id) VALUES ('b', -100, 'USD', '2024-01-01', 1)`,
+		`INSERT INTO transactions (description, amount, date, account_id) VALUES ('c', -100, '2024-01-01', 2)`,
+		`INSERT INTO transactions (description, amount, date) VALUES ('d', -100, '2024-01-01')`,
+	} {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := conn.QueryContext(ctx, `SELECT description, COALESCE(currency, '') FROM transactions ORDER BY description`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var description, currency string
+		if err := rows.Scan(&description, &currency); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, description+" "+currency)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a EUR", "b USD", "c ", "d "}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("currencies = %q, want %q", got, want)
+	}
+}
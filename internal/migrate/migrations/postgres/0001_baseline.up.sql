@@ -0,0 +1,140 @@
+-- The baseline is the schema that scripts/db_init.sql used to create. It is
+-- written so it can also run over a database created from any version of
+-- that script, which adopts the database: missing tables, columns and
+-- indexes are added and everything else is left alone.
+
+-- Remember whether this adopts an existing database before creating anything.
+CREATE TEMP TABLE baseline_adopted ON COMMIT DROP AS
+  SELECT to_regclass('categories') IS NOT NULL AS adopted;
+
+CREATE TABLE IF NOT EXISTS categories (
+  id bigserial PRIMARY KEY,
+  name varchar(100) NOT NULL UNIQUE
+);
+
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id bigint REFERENCES categories(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS accounts (
+  id bigserial PRIMARY KEY,
+  name varchar(100) NOT NULL UNIQUE,
+  type varchar(20) NOT NULL DEFAULT 'checking',
+  institution varchar(100),
+  currency char(3),
+  last4 char(4)
+);
+
+CREATE TABLE IF NOT EXISTS imports (
+  id bigserial PRIMARY KEY,
+  file_name varchar(255) NOT NULL,
+  content_hash char(64) NOT NULL,
+  parser varchar(50) NOT NULL,
+  model varchar(100),
+  row_count integer NOT NULL DEFAULT 0,
+  imported_at timestamp DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE imports ADD COLUMN IF NOT EXISTS account_id bigint REFERENCES accounts(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS transactions(
+  id bigserial PRIMARY KEY,
+  description varchar(255) NOT NULL,
+  category_id bigint,
+  amount decimal(10, 2) NOT NULL,
+  date date NOT NULL,
+  created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+);
+
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS fitid varchar(255);
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS import_id bigint REFERENCES imports(id) ON DELETE CASCADE;
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS account_id bigint REFERENCES accounts(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS category_rules (
+  id bigserial PRIMARY KEY,
+  name varchar(100) NOT NULL,
+  pattern varchar(255) NOT NULL,
+  is_regex boolean NOT NULL DEFAULT false,
+  min_amount decimal(10, 2),
+  max_amount decimal(10, 2),
+  account varchar(100),
+  category_id bigint NOT NULL,
+  priority integer NOT NULL DEFAULT 0,
+  created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS merchant_memory (
+  id bigserial PRIMARY KEY,
+  pattern varchar(255) NOT NULL UNIQUE,
+  category_id bigint NOT NULL,
+  hits integer NOT NULL DEFAULT 1,
+  updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS transaction_splits (
+  id bigserial PRIMARY KEY,
+  transaction_id bigint NOT NULL,
+  category_id bigint,
+  amount decimal(10, 2) NOT NULL,
+  FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+  FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+);
+
+-- allocations has one row per split, or the transaction itself when it is
+-- not split. Category aggregates read from it.
+CREATE OR REPLACE VIEW allocations AS
+SELECT t.id AS transaction_id, t.date,
+  CASE WHEN s.id IS NULL THEN t.category_id ELSE s.category_id END AS category_id,
+  CASE WHEN s.id IS NULL THEN t.amount ELSE s.amount END AS amount,
+  t.account_id
+FROM transactions t
+LEFT JOIN transaction_splits s ON s.transaction_id = t.id;
+
+CREATE TABLE IF NOT EXISTS tags (
+  id bigserial PRIMARY KEY,
+  name varchar(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS transaction_tags (
+  transaction_id bigint NOT NULL,
+  tag_id bigint NOT NULL,
+  PRIMARY KEY (transaction_id, tag_id),
+  FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+  FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent ON categories(parent_id);
+CREATE INDEX IF NOT EXISTS idx_imports_content_hash ON imports(content_hash);
+CREATE INDEX IF NOT EXISTS idx_transactions_category ON transactions(category_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_import ON transactions(import_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_account ON transactions(account_id);
+CREATE INDEX IF NOT EXISTS idx_transaction_tags_tag ON transaction_tags(tag_id);
+CREATE INDEX IF NOT EXISTS idx_transaction_splits_transaction ON transaction_splits(transaction_id);
+
+-- FITIDs are only unique within an account. Older databases indexed the
+-- FITID alone.
+DROP INDEX IF EXISTS idx_transactions_fitid;
+CREATE UNIQUE INDEX idx_transactions_fitid ON transactions(COALESCE(account_id, 0), fitid) WHERE fitid IS NOT NULL;
+
+-- db_init.sql seeded these categories. A database it created keeps them;
+-- fresh databases are seeded from the configured categories on connect.
+INSERT INTO categories (name)
+SELECT name FROM (VALUES
+  ('income'),
+  ('interest'),
+  ('rent'),
+  ('utilities'),
+  ('insurance'),
+  ('dining'),
+  ('groceries'),
+  ('shopping'),
+  ('entertainment'),
+  ('subscriptions'),
+  ('travel'),
+  ('gifts'),
+  ('investment'),
+  ('emergency')
+) AS seed(name)
+WHERE (SELECT adopted FROM baseline_adopted)
+ON CONFLICT (name) DO NOTHING;
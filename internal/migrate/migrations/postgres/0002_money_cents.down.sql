@@ -0,0 +1,17 @@
+-- Amounts beyond 99,999,999.99 no longer fit and make the rollback fail.
+
+DROP VIEW allocations;
+
+ALTER TABLE transactions ALTER COLUMN amount TYPE decimal(10, 2) USING amount / 100.0;
+ALTER TABLE transactions DROP COLUMN currency;
+ALTER TABLE transaction_splits ALTER COLUMN amount TYPE decimal(10, 2) USING amount / 100.0;
+ALTER TABLE category_rules ALTER COLUMN min_amount TYPE decimal(10, 2) USING min_amount / 100.0;
+ALTER TABLE category_rules ALTER COLUMN max_amount TYPE decimal(10, 2) USING max_amount / 100.0;
+
+CREATE VIEW allocations AS
+SELECT t.id AS transaction_id, t.date,
+  CASE WHEN s.id IS NULL THEN t.category_id ELSE s.category_id END AS category_id,
+  CASE WHEN s.id IS NULL THEN t.amount ELSE s.amount END AS amount,
+  t.account_id
+FROM transactions t
+LEFT JOIN transaction_splits s ON s.transaction_id = t.id;
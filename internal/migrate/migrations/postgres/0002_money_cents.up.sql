@@ -0,0 +1,18 @@
+-- Amounts are stored as whole cents, so sums are exact and no longer capped
+-- at 99,999,999.99. Transactions also record their currency.
+
+DROP VIEW allocations;
+
+ALTER TABLE transactions ALTER COLUMN amount TYPE bigint USING round(amount * 100);
+ALTER TABLE transactions ADD COLUMN currency char(3);
+ALTER TABLE transaction_splits ALTER COLUMN amount TYPE bigint USING round(amount * 100);
+ALTER TABLE category_rules ALTER COLUMN min_amount TYPE bigint USING round(min_amount * 100);
+ALTER TABLE category_rules ALTER COLUMN max_amount TYPE bigint USING round(max_amount * 100);
+
+CREATE VIEW allocations AS
+SELECT t.id AS transaction_id, t.date,
+  CASE WHEN s.id IS NULL THEN t.category_id ELSE s.category_id END AS category_id,
+  CASE WHEN s.id IS NULL THEN t.amount ELSE s.amount END AS amount,
+  t.account_id
+FROM transactions t
+LEFT JOIN transaction_splits s ON s.transaction_id = t.id;
@@ -0,0 +1,7 @@
+-- pg_trgm is left installed, other database objects may rely on it.
+
+DROP INDEX IF EXISTS idx_transactions_notes_trgm;
+DROP INDEX IF EXISTS idx_transactions_description_trgm;
+DROP INDEX IF EXISTS idx_transactions_search;
+
+ALTER TABLE transactions DROP COLUMN notes;
@@ -0,0 +1,11 @@
+-- Transactions get free-form notes, and search over descriptions and notes
+-- is backed by a full-text index and trigram indexes for fuzzy matches.
+
+ALTER TABLE transactions ADD COLUMN notes text;
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_transactions_search ON transactions
+  USING gin (to_tsvector('simple', description || ' ' || COALESCE(notes, '')));
+CREATE INDEX idx_transactions_description_trgm ON transactions USING gin (description gin_trgm_ops);
+CREATE INDEX idx_transactions_notes_trgm ON transactions USING gin (notes gin_trgm_ops);
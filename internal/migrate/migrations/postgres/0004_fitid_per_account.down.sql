@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transactions_fitid;
+CREATE UNIQUE INDEX idx_transactions_fitid ON transactions(COALESCE(account_id, 0), fitid) WHERE fitid IS NOT NULL;
@@ -0,0 +1,7 @@
+-- FITIDs were unique per COALESCE(account_id, 0), which made deleting an
+-- account fail when one of its FITIDs was also imported without an account.
+-- Rows without an account are now left out of the index and deduplicated
+-- when they are inserted.
+
+DROP INDEX IF EXISTS idx_transactions_fitid;
+CREATE UNIQUE INDEX idx_transactions_fitid ON transactions(account_id, fitid) WHERE fitid IS NOT NULL;
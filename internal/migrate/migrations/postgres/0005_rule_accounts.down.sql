@@ -0,0 +1,7 @@
+ALTER TABLE category_rules ADD COLUMN account varchar(100);
+
+UPDATE category_rules
+SET account = (SELECT a.name FROM accounts a WHERE a.id = category_rules.account_id);
+
+DROP INDEX IF EXISTS idx_category_rules_account;
+ALTER TABLE category_rules DROP COLUMN account_id;
@@ -0,0 +1,9 @@
+DROP VIEW allocations;
+
+CREATE VIEW allocations AS
+SELECT t.id AS transaction_id, t.date,
+  CASE WHEN s.id IS NULL THEN t.category_id ELSE s.category_id END AS category_id,
+  CASE WHEN s.id IS NULL THEN t.amount ELSE s.amount END AS amount,
+  t.account_id
+FROM transactions t
+LEFT JOIN transaction_splits s ON s.transaction_id = t.id;
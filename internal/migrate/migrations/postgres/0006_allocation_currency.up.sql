@@ -0,0 +1,13 @@
+-- Totals per category are kept apart by currency, so allocations carry the
+-- currency of their transaction.
+
+DROP VIEW allocations;
+
+CREATE VIEW allocations AS
+SELECT t.id AS transaction_id, t.date,
+  CASE WHEN s.id IS NULL THEN t.category_id ELSE s.category_id END AS category_id,
+  CASE WHEN s.id IS NULL THEN t.amount ELSE s.amount END AS amount,
+  t.account_id,
+  t.currency
+FROM transactions t
+LEFT JOIN transaction_splits s ON s.transaction_id = t.id;
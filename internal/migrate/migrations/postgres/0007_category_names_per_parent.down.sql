@@ -0,0 +1,5 @@
+DROP VIEW category_paths;
+
+DROP INDEX idx_categories_parent_name;
+
+ALTER TABLE categories ADD CONSTRAINT categories_name_key UNIQUE (name);
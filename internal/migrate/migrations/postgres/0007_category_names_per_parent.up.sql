@@ -0,0 +1,20 @@
+-- Category names only need to be unique among their siblings, so
+-- "Food > Other" and "Housing > Other" can both exist. Categories are
+-- referred to by name where that is unambiguous and by path otherwise;
+-- category_paths gives both for every category.
+
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_name_key;
+
+CREATE UNIQUE INDEX idx_categories_parent_name ON categories (COALESCE(parent_id, 0), name);
+
+CREATE VIEW category_paths AS
+WITH RECURSIVE tree (id, name, parent_id, path) AS (
+  SELECT id, name, parent_id, CAST(name AS text) FROM categories WHERE parent_id IS NULL
+  UNION ALL
+  SELECT c.id, c.name, c.parent_id, tree.path || ' > ' || c.name
+  FROM categories c
+  JOIN tree ON c.parent_id = tree.id
+)
+SELECT id, name, parent_id, path,
+  CASE WHEN (SELECT COUNT(*) FROM categories d WHERE d.name = tree.name) > 1 THEN path ELSE name END AS ref
+FROM tree;
@@ -0,0 +1,111 @@
+-- SQLite has no scripts/db_init.sql history to adopt, so its baseline is the
+-- plain schema. Dates are stored as YYYY-MM-DD text, which sorts and
+-- compares like a date.
+
+CREATE TABLE categories (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name varchar(100) NOT NULL UNIQUE,
+  parent_id bigint,
+  FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE SET NULL
+);
+
+CREATE TABLE accounts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name varchar(100) NOT NULL UNIQUE,
+  type varchar(20) NOT NULL DEFAULT 'checking',
+  institution varchar(100),
+  currency char(3),
+  last4 char(4)
+);
+
+CREATE TABLE imports (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  file_name varchar(255) NOT NULL,
+  content_hash char(64) NOT NULL,
+  parser varchar(50) NOT NULL,
+  model varchar(100),
+  account_id bigint,
+  row_count integer NOT NULL DEFAULT 0,
+  imported_at timestamp DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE SET NULL
+);
+
+CREATE TABLE transactions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  description varchar(255) NOT NULL,
+  category_id bigint,
+  amount decimal(10, 2) NOT NULL,
+  date date NOT NULL,
+  fitid varchar(255),
+  import_id bigint,
+  account_id bigint,
+  created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL,
+  FOREIGN KEY (import_id) REFERENCES imports(id) ON DELETE CASCADE,
+  FOREIGN KEY (account_id) REFERENCES accounts(id) ON DELETE SET NULL
+);
+
+CREATE TABLE category_rules (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name varchar(100) NOT NULL,
+  pattern varchar(255) NOT NULL,
+  is_regex boolean NOT NULL DEFAULT false,
+  min_amount decimal(10, 2),
+  max_amount decimal(10, 2),
+  account varchar(100),
+  category_id bigint NOT NULL,
+  priority integer NOT NULL DEFAULT 0,
+  created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
+CREATE TABLE merchant_memory (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  pattern varchar(255) NOT NULL UNIQUE,
+  category_id bigint NOT NULL,
+  hits integer NOT NULL DEFAULT 1,
+  updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
+CREATE TABLE transaction_splits (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  transaction_id bigint NOT NULL,
+  category_id bigint,
+  amount decimal(10, 2) NOT NULL,
+  FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+  FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE SET NULL
+);
+
+-- allocations has one row per split, or the transaction itself when it is
+-- not split. Category aggregates read from it.
+CREATE VIEW allocations AS
+SELECT t.id AS transaction_id, t.date,
+  CASE WHEN s.id IS NULL THEN t.category_id ELSE s.category_id END AS category_id,
+  CASE WHEN s.id IS NULL THEN t.amount ELSE s.amount END AS amount,
+  t.account_id
+FROM transactions t
+LEFT JOIN transaction_splits s ON s.transaction_id = t.id;
+
+CREATE TABLE tags (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name varchar(100) NOT NULL UNIQUE
+);
+
+CREATE TABLE transaction_tags (
+  transaction_id bigint NOT NULL,
+  tag_id bigint NOT NULL,
+  PRIMARY KEY (transaction_id, tag_id),
+  FOREIGN KEY (transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+  FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_categories_parent ON categories(parent_id);
+CREATE INDEX idx_imports_content_hash ON imports(content_hash);
+CREATE INDEX idx_transactions_category ON transactions(category_id);
+CREATE INDEX idx_transactions_import ON transactions(import_id);
+CREATE INDEX idx_transactions_account ON transactions(account_id);
+CREATE INDEX idx_transaction_tags_tag ON transaction_tags(tag_id);
+CREATE INDEX idx_transaction_splits_transaction ON transaction_splits(transaction_id);
+-- FITIDs are only unique within an account.
+CREATE UNIQUE INDEX idx_transactions_fitid ON transactions(COALESCE(account_id, 0), fitid) WHERE fitid IS NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE transactions DROP COLUMN currency;
+
+UPDATE transactions SET amount = amount / 100.0;
+UPDATE transaction_splits SET amount = amount / 100.0;
+UPDATE category_rules SET min_amount = min_amount / 100.0, max_amount = max_amount / 100.0;
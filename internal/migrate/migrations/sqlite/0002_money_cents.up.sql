@@ -0,0 +1,13 @@
+-- Amounts are stored as whole cents, so sums are exact, and transactions
+-- record their currency. SQLite cannot change a column's type, and
+-- rebuilding the tables would cascade deletes through their foreign keys.
+-- The decimal columns have NUMERIC affinity, which keeps whole numbers as
+-- 64-bit integers, so the values are converted in place.
+
+UPDATE transactions SET amount = CAST(ROUND(amount * 100) AS INTEGER);
+UPDATE transaction_splits SET amount = CAST(ROUND(amount * 100) AS INTEGER);
+UPDATE category_rules SET
+  min_amount = CAST(ROUND(min_amount * 100) AS INTEGER),
+  max_amount = CAST(ROUND(max_amount * 100) AS INTEGER);
+
+ALTER TABLE transactions ADD COLUMN currency char(3);
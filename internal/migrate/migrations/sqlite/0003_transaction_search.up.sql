@@ -0,0 +1,4 @@
+-- Transactions get free-form notes. SQLite has no trigram matching, so
+-- search ranks the candidates in Go instead of using an index.
+
+ALTER TABLE transactions ADD COLUMN notes text;
@@ -0,0 +1,24 @@
+-- SQLite cannot drop a column with a foreign key, so the table is rebuilt.
+-- Nothing references category_rules, so dropping it cascades nowhere.
+
+CREATE TABLE category_rules_old (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name varchar(100) NOT NULL,
+  pattern varchar(255) NOT NULL,
+  is_regex boolean NOT NULL DEFAULT false,
+  min_amount decimal(10, 2),
+  max_amount decimal(10, 2),
+  account varchar(100),
+  category_id bigint NOT NULL,
+  priority integer NOT NULL DEFAULT 0,
+  created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+  FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
+INSERT INTO category_rules_old (id, name, pattern, is_regex, min_amount, max_amount, account, category_id, priority, created_at)
+SELECT r.id, r.name, r.pattern, r.is_regex, r.min_amount, r.max_amount, a.name, r.category_id, r.priority, r.created_at
+FROM category_rules r
+LEFT JOIN accounts a ON a.id = r.account_id;
+
+DROP TABLE category_rules;
+ALTER TABLE category_rules_old RENAME TO category_rules;
@@ -0,0 +1,19 @@
+-- Rules were limited to an account by the free-text account hint of a
+-- statement. They now reference the account itself. Hints that name no
+-- account become new accounts, so no rule loses its scope.
+
+INSERT INTO accounts (name)
+SELECT MIN(account) FROM category_rules
+WHERE account IS NOT NULL AND account <> ''
+  AND NOT EXISTS (SELECT 1 FROM accounts a WHERE LOWER(a.name) = LOWER(category_rules.account))
+GROUP BY LOWER(account);
+
+ALTER TABLE category_rules ADD COLUMN account_id bigint REFERENCES accounts(id) ON DELETE CASCADE;
+
+UPDATE category_rules
+SET account_id = (SELECT MIN(a.id) FROM accounts a WHERE LOWER(a.name) = LOWER(category_rules.account))
+WHERE account IS NOT NULL AND account <> '';
+
+ALTER TABLE category_rules DROP COLUMN account;
+
+CREATE INDEX idx_category_rules_account ON category_rules(account_id);
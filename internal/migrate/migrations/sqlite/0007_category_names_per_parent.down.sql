@@ -0,0 +1,18 @@
+-- Rolling back fails while two categories share a name.
+
+DROP VIEW category_paths;
+
+CREATE TABLE categories_old (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name varchar(100) NOT NULL UNIQUE,
+  parent_id bigint,
+  FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE SET NULL
+);
+
+INSERT INTO categories_old (id, name, parent_id)
+SELECT id, name, parent_id FROM categories;
+
+DROP TABLE categories;
+ALTER TABLE categories_old RENAME TO categories;
+
+CREATE INDEX idx_categories_parent ON categories(parent_id);
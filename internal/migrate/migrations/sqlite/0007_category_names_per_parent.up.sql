@@ -0,0 +1,36 @@
+-- Category names only need to be unique among their siblings, so
+-- "Food > Other" and "Housing > Other" can both exist. Categories are
+-- referred to by name where that is unambiguous and by path otherwise;
+-- category_paths gives both for every category.
+--
+-- SQLite cannot drop the UNIQUE constraint of a column, so the table is
+-- rebuilt. Foreign keys are off while migrations run, so dropping the old
+-- table leaves the transactions referencing it alone.
+
+CREATE TABLE categories_new (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  name varchar(100) NOT NULL,
+  parent_id bigint,
+  FOREIGN KEY (parent_id) REFERENCES categories(id) ON DELETE SET NULL
+);
+
+INSERT INTO categories_new (id, name, parent_id)
+SELECT id, name, parent_id FROM categories;
+
+DROP TABLE categories;
+ALTER TABLE categories_new RENAME TO categories;
+
+CREATE INDEX idx_categories_parent ON categories(parent_id);
+CREATE UNIQUE INDEX idx_categories_parent_name ON categories (COALESCE(parent_id, 0), name);
+
+CREATE VIEW category_paths AS
+WITH RECURSIVE tree (id, name, parent_id, path) AS (
+  SELECT id, name, parent_id, CAST(name AS text) FROM categories WHERE parent_id IS NULL
+  UNION ALL
+  SELECT c.id, c.name, c.parent_id, tree.path || ' > ' || c.name
+  FROM categories c
+  JOIN tree ON c.parent_id = tree.id
+)
+SELECT id, name, parent_id, path,
+  CASE WHEN (SELECT COUNT(*) FROM categories d WHERE d.name = tree.name) > 1 THEN path ELSE name END AS ref
+FROM tree;
@@ -0,0 +1,2 @@
+-- Which currencies were filled in is not recorded, so they are kept.
+SELECT 1;
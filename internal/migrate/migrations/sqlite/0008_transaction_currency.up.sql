@@ -0,0 +1,8 @@
+-- CSV and PDF statements name no currency, so their transactions were
+-- stored without one. They are in the currency of their account where it
+-- has one; the rest get the configured default at startup.
+
+UPDATE transactions
+SET currency = (SELECT a.currency FROM accounts a WHERE a.id = transactions.account_id)
+WHERE currency IS NULL
+  AND account_id IN (SELECT id FROM accounts WHERE currency IS NOT NULL AND currency <> '');
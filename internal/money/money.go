@@ -0,0 +1,273 @@
+// Package money holds amounts of money exactly, as whole cents, so sums do
+// not drift the way float64 arithmetic does.
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in cents, the minor unit moni uses for every currency,
+// and the ISO 4217 code of its currency. An empty Currency means the amount
+// is in the user's usual currency.
+type Money struct {
+	Cents    int64
+	Currency string
+}
+
+func New(cents int64, currency string) Money {
+	return Money{Cents: cents, Currency: strings.ToUpper(currency)}
+}
+
+// Parse reads a decimal amount such as "-1234.56", "12" or "1e3" without
+// going through float64. Digits beyond the cent are rounded half away from
+// zero.
+func Parse(s string) (Money, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "+")
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+
+	r.Mul(r, big.NewRat(100, 1))
+	q, rem := new(big.Int).QuoRem(r.Num(), r.Denom(), new(big.Int))
+	// Round half away from zero: |2 * remainder| >= denominator.
+	if rem.Abs(rem).Lsh(rem, 1).Cmp(r.Denom()) >= 0 {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	if !q.IsInt64() {
+		return Money{}, fmt.Errorf("amount %q is too large", s)
+	}
+	return Money{Cents: q.Int64()}, nil
+}
+
+// In returns m in currency.
+func (m Money) In(currency string) Money {
+	m.Currency = strings.ToUpper(currency)
+	return m
+}
+
+// ErrCurrencyMismatch is returned when amounts in two different currencies
+// are combined.
+var ErrCurrencyMismatch = errors.New("currencies differ")
+
+// TryAdd returns m + o. The result keeps m's currency, or takes o's when m
+// has none. Amounts in two different currencies cannot be added and TryAdd
+// returns ErrCurrencyMismatch; use Totals to add up amounts that may be in
+// several currencies.
+func (m Money) TryAdd(o Money) (Money, error) {
+	if m.Currency != "" && o.Currency != "" && m.Currency != o.Currency {
+		return m, fmt.Errorf("cannot add %s to %s: %w", o, m, ErrCurrencyMismatch)
+	}
+	if m.Currency == "" {
+		m.Currency = o.Currency
+	}
+	m.Cents += o.Cents
+	return m, nil
+}
+
+func (m Money) TrySub(o Money) (Money, error) {
+	return m.TryAdd(o.Neg())
+}
+
+// Add is TryAdd for amounts already known to share a currency, such as two
+// columns of the same row. It panics when they do not.
+func (m Money) Add(o Money) Money {
+	sum, err := m.TryAdd(o)
+	if err != nil {
+		panic("money: " + err.Error())
+	}
+	return sum
+}
+
+func (m Money) Sub(o Money) Money {
+	return m.Add(o.Neg())
+}
+
+func (m Money) Neg() Money {
+	m.Cents = -m.Cents
+	return m
+}
+
+func (m Money) Abs() Money {
+	if m.Cents < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+func (m Money) IsZero() bool {
+	return m.Cents == 0
+}
+
+// Sign returns -1, 0 or 1 depending on the sign of m.
+func (m Money) Sign() int {
+	switch {
+	case m.Cents < 0:
+		return -1
+	case m.Cents > 0:
+		return 1
+	}
+	return 0
+}
+
+// Float returns m in whole units, for statistics that do not need to be
+// exact.
+func (m Money) Float() float64 {
+	return float64(m.Cents) / 100
+}
+
+// Decimal formats m as a plain number with two decimals, e.g. "-1234.56".
+func (m Money) Decimal() string {
+	sign := ""
+	cents := m.Cents
+	if cents < 0 {
+		sign = "-"
+	}
+	// Two's complement leaves no positive counterpart for the minimum, so
+	// the digits are taken from the unsigned value.
+	u := uint64(cents)
+	if cents < 0 {
+		u = -u
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, u/100, u%100)
+}
+
+// String formats m with its currency when it has one, e.g. "-12.50 EUR".
+func (m Money) String() string {
+	if m.Currency == "" {
+		return m.Decimal()
+	}
+	return m.Decimal() + " " + m.Currency
+}
+
+// MarshalJSON writes m as a JSON number. The currency is not included.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.Decimal()), nil
+}
+
+// UnmarshalJSON reads a JSON number, or a string holding one, exactly.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("invalid amount %s", data)
+		}
+		s = n.String()
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	m.Cents = parsed.Cents
+	return nil
+}
+
+// Value stores m as its number of cents. The currency has a column of its
+// own.
+func (m Money) Value() (driver.Value, error) {
+	return m.Cents, nil
+}
+
+// Scan reads a number of cents. Sums may come back as floats or, from
+// Postgres numerics, as text.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		m.Cents = 0
+	case int64:
+		m.Cents = v
+	case float64:
+		m.Cents = int64(math.Round(v))
+	case []byte:
+		return m.scanText(string(v))
+	case string:
+		return m.scanText(v)
+	default:
+		return fmt.Errorf("cannot scan %T into money", src)
+	}
+	return nil
+}
+
+func (m *Money) scanText(s string) error {
+	if cents, err := strconv.ParseInt(s, 10, 64); err == nil {
+		m.Cents = cents
+		return nil
+	}
+	// A numeric such as "1234.0" holds cents too, so parsing it as units
+	// and scaling back keeps it exact.
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	if parsed.Cents%100 != 0 {
+		return fmt.Errorf("cannot scan %q into money: not a whole number of cents", s)
+	}
+	m.Cents = parsed.Cents / 100
+	return nil
+}
+
+// Totals adds up amounts separately per currency, so amounts in different
+// currencies are never mixed. It is sorted by currency, with amounts without
+// a currency first.
+type Totals []Money
+
+// Add returns t with m added to the total in m's currency.
+func (t Totals) Add(m Money) Totals {
+	i, found := slices.BinarySearchFunc(t, m.Currency, func(total Money, currency string) int {
+		return strings.Compare(total.Currency, currency)
+	})
+	if found {
+		t[i].Cents += m.Cents
+		return t
+	}
+	if m.IsZero() {
+		return t
+	}
+	return slices.Insert(t, i, m)
+}
+
+// In returns the total in currency.
+func (t Totals) In(currency string) Money {
+	currency = strings.ToUpper(currency)
+	for _, total := range t {
+		if total.Currency == currency {
+			return total
+		}
+	}
+	return New(0, currency)
+}
+
+// IsZero reports whether the total in every currency is zero.
+func (t Totals) IsZero() bool {
+	for _, total := range t {
+		if !total.IsZero() {
+			return false
+		}
+	}
+	return true
+}
+
+// String lists the non-zero totals, e.g. "-12.50, 40.00 EUR", or "0.00"
+// when there are none.
+func (t Totals) String() string {
+	var parts []string
+	for _, total := range t {
+		if !total.IsZero() {
+			parts = append(parts, total.String())
+		}
+	}
+	if len(parts) == 0 {
+		return Money{}.String()
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,176 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in    string
+		cents int64
+	}{
+		{"0", 0},
+		{"12", 1200},
+		{"+12.5", 1250},
+		{"-1234.56", -123456},
+		{"0.1", 10},
+		{"0.005", 1},
+		{"-0.005", -1},
+		{"0.0049", 0},
+		{"1e3", 100000},
+		{"123456789012.34", 12345678901234},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got.Cents != tt.cents {
+			t.Errorf("Parse(%q) = %d cents, want %d", tt.in, got.Cents, tt.cents)
+		}
+	}
+
+	for _, in := range []string{"", "abc", "1,5", "99999999999999999999"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) succeeded", in)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{New(0, ""), "0.00"},
+		{New(5, ""), "0.05"},
+		{New(-5, ""), "-0.05"},
+		{New(-123456, "eur"), "-1234.56 EUR"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestSumIsExact(t *testing.T) {
+	dime, err := Parse("0.10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sum Money
+	for range 10 {
+		sum = sum.Add(dime)
+	}
+	if sum != New(100, "") {
+		t.Errorf("ten times 0.10 = %s, want 1.00", sum)
+	}
+}
+
+func TestAddRefusesToMixCurrencies(t *testing.T) {
+	if got := New(100, "EUR").Add(New(50, "")); got != New(150, "EUR") {
+		t.Errorf("1.00 EUR + 0.50 = %s, want 1.50 EUR", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("adding USD to EUR did not panic")
+		}
+	}()
+	New(100, "EUR").Add(New(100, "USD"))
+}
+
+func TestTryAdd(t *testing.T) {
+	if got, err := New(100, "").TrySub(New(30, "USD")); err != nil || got != New(70, "USD") {
+		t.Errorf("1.00 - 0.30 USD = %s, %v, want 0.70 USD", got, err)
+	}
+	if _, err := New(100, "EUR").TryAdd(New(100, "USD")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("adding USD to EUR: err = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestTotals(t *testing.T) {
+	var totals Totals
+	if !totals.IsZero() || totals.String() != "0.00" {
+		t.Errorf("empty totals = %s", totals)
+	}
+	for _, m := range []Money{New(1000, "usd"), New(-250, ""), New(0, "GBP"), New(500, "EUR"), New(-1000, "USD")} {
+		totals = totals.Add(m)
+	}
+
+	want := Totals{New(-250, ""), New(500, "EUR"), New(0, "USD")}
+	if len(totals) != len(want) {
+		t.Fatalf("totals = %v, want %v", []Money(totals), []Money(want))
+	}
+	for i := range want {
+		if totals[i] != want[i] {
+			t.Errorf("totals[%d] = %s, want %s", i, totals[i], want[i])
+		}
+	}
+	if got := totals.String(); got != "-2.50, 5.00 EUR" {
+		t.Errorf("String() = %q, want %q", got, "-2.50, 5.00 EUR")
+	}
+	if got := totals.In("eur"); got != New(500, "EUR") {
+		t.Errorf("In(eur) = %s, want 5.00 EUR", got)
+	}
+	if got := totals.In("GBP"); got != New(0, "GBP") {
+		t.Errorf("In(GBP) = %s, want 0.00 GBP", got)
+	}
+	if totals.IsZero() {
+		t.Error("totals with a non-zero currency are zero")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var tx struct {
+		Amount Money `json:"amount"`
+	}
+	for _, in := range []string{`{"amount": -4.35}`, `{"amount": "-4.35"}`} {
+		if err := json.Unmarshal([]byte(in), &tx); err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if tx.Amount.Cents != -435 {
+			t.Errorf("%s decoded to %d cents, want -435", in, tx.Amount.Cents)
+		}
+	}
+	if err := json.Unmarshal([]byte(`{"amount": true}`), &tx); err == nil {
+		t.Error("decoding a boolean amount succeeded")
+	}
+
+	out, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != `{"amount":-4.35}` {
+		t.Errorf("Marshal = %s", out)
+	}
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		src   any
+		cents int64
+	}{
+		{int64(-435), -435},
+		{float64(1200), 1200},
+		{[]byte("123456"), 123456},
+		{"1200.0", 1200},
+		{nil, 0},
+	}
+	for _, tt := range tests {
+		m := New(1, "USD")
+		if err := m.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%v): %v", tt.src, err)
+			continue
+		}
+		if m.Cents != tt.cents || m.Currency != "USD" {
+			t.Errorf("Scan(%v) = %#v, want %d USD cents", tt.src, m, tt.cents)
+		}
+	}
+	if err := new(Money).Scan("12.5"); err == nil {
+		t.Error("scanning a fraction of a cent succeeded")
+	}
+}
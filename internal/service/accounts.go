@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/dylanewe/moni/internal/store"
+)
+
+// StatementText returns the text of a statement for account detection. PDFs
+// go through the text extractor, other statements are text already.
+func (s *Service) StatementText(ctx context.Context, file string) (string, error) {
+	if strings.ToLower(filepath.Ext(file)) == ".pdf" {
+		doc, err := s.Extractor.ExtractText(ctx, file)
+		if err != nil {
+			return "", err
+		}
+		return doc.Text(), nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// DetectAccount guesses which account a statement belongs to from its file
+// name, its text and the account hints of its parsed transactions. The last
+// four digits of an account weigh most, then its name and then its
+// institution. No account is returned when the best guess is a tie.
+func DetectAccount(accounts []store.Account, file, text string, transactions []store.Transaction) (store.Account, bool) {
+	hints := []string{filepath.Base(file), text}
+	for _, tx := range transactions {
+		if tx.Account != "" {
+			hints = append(hints, tx.Account)
+		}
+	}
+	haystack := strings.ToLower(strings.Join(hints, "\n"))
+
+	var best store.Account
+	bestScore, ties := 0, 0
+	for _, acc := range accounts {
+		score := 0
+		if acc.Last4 != "" && containsLast4(haystack, acc.Last4) {
+			score += 4
+		}
+		if acc.Name != "" && strings.Contains(haystack, strings.ToLower(acc.Name)) {
+			score += 2
+		}
+		if acc.Institution != "" && strings.Contains(haystack, strings.ToLower(acc.Institution)) {
+			score++
+		}
+
+		switch {
+		case score > bestScore:
+			best, bestScore, ties = acc, score, 0
+		case score == bestScore && score > 0:
+			ties++
+		}
+	}
+
+	if bestScore == 0 || ties > 0 {
+		return store.Account{}, false
+	}
+	return best, true
+}
+
+// containsLast4 reports whether the digits end an account number or appear
+// on their own, as in "xxxx-1234" or "ending 1234", rather than inside some
+// other number such as an amount.
+func containsLast4(s, last4 string) bool {
+	re := regexp.MustCompile(`(^|[^0-9])([0-9]{4,})?` + regexp.QuoteMeta(last4) + `([^0-9.,]|[.,][^0-9]|[.,]?$)`)
+	return re.MatchString(s)
+}
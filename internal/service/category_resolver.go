@@ -0,0 +1,164 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const defaultCategoryMatchThreshold = 0.8
+
+// CategoryResolver maps free-form category names, as produced by a model or
+// found in a bank export, onto the canonical category list.
+type CategoryResolver struct {
+	// synonyms maps a normalized synonym to its canonical category.
+	synonyms map[string]string
+	// synonymKeys holds the keys of synonyms in order, so that fuzzy
+	// matching breaks ties the same way every run.
+	synonymKeys []string
+	threshold   float64
+}
+
+func NewCategoryResolver(synonyms map[string][]string, threshold float64) *CategoryResolver {
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultCategoryMatchThreshold
+	}
+
+	r := &CategoryResolver{
+		synonyms:  make(map[string]string),
+		threshold: threshold,
+	}
+	// Walk the categories in order so a synonym listed under two categories
+	// always resolves to the same one.
+	categories := make([]string, 0, len(synonyms))
+	for category := range synonyms {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		for _, w := range synonyms[category] {
+			key := singularize(normalizeCategory(w))
+			if _, ok := r.synonyms[key]; ok || key == "" {
+				continue
+			}
+			r.synonyms[key] = category
+			r.synonymKeys = append(r.synonymKeys, key)
+		}
+	}
+	sort.Strings(r.synonymKeys)
+
+	return r
+}
+
+// Resolve returns the canonical category for raw together with a confidence
+// between 0 and 1. It returns an empty category when nothing matches with at
+// least the configured threshold.
+func (r *CategoryResolver) Resolve(categories []string, raw string) (string, float64) {
+	name := normalizeCategory(raw)
+	if name == "" {
+		return "", 0
+	}
+
+	for _, c := range categories {
+		if normalizeCategory(c) == name {
+			return c, 1
+		}
+	}
+
+	singular := singularize(name)
+	if c, ok := r.synonyms[singular]; ok && containsFold(categories, c) {
+		return canonical(categories, c), 1
+	}
+	for _, c := range categories {
+		if singularize(normalizeCategory(c)) == singular {
+			return c, 0.95
+		}
+	}
+
+	best, bestScore := "", 0.0
+	for _, c := range categories {
+		if score := similarity(singular, singularize(normalizeCategory(c))); score > bestScore {
+			best, bestScore = c, score
+		}
+	}
+	for _, synonym := range r.synonymKeys {
+		c := r.synonyms[synonym]
+		if !containsFold(categories, c) {
+			continue
+		}
+		if score := similarity(singular, synonym); score > bestScore {
+			best, bestScore = canonical(categories, c), score
+		}
+	}
+
+	if bestScore < r.threshold {
+		return "", bestScore
+	}
+
+	return best, bestScore
+}
+
+func normalizeCategory(s string) string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
+
+func singularize(phrase string) string {
+	words := strings.Fields(phrase)
+	for i, w := range words {
+		switch {
+		case len(w) > 4 && strings.HasSuffix(w, "ies"):
+			words[i] = strings.TrimSuffix(w, "ies") + "y"
+		case strings.HasSuffix(w, "sses"), strings.HasSuffix(w, "ches"),
+			strings.HasSuffix(w, "shes"), strings.HasSuffix(w, "xes"):
+			words[i] = strings.TrimSuffix(w, "es")
+		case len(w) > 3 && strings.HasSuffix(w, "s") &&
+			!strings.HasSuffix(w, "ss") && !strings.HasSuffix(w, "us") && !strings.HasSuffix(w, "is"):
+			words[i] = strings.TrimSuffix(w, "s")
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// similarity is the Levenshtein distance between a and b scaled to [0, 1],
+// where 1 means equal.
+func similarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) == 0 && len(rb) == 0 {
+		return 1
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
+
+func containsFold(categories []string, name string) bool {
+	return canonical(categories, name) != ""
+}
+
+func canonical(categories []string, name string) string {
+	for _, c := range categories {
+		if strings.EqualFold(c, name) {
+			return c
+		}
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dylanewe/moni/internal/store"
+)
+
+func TestCategoryResolver(t *testing.T) {
+	categories := []string{"Groceries", "Dining", "Transport", "Rent", "Utilities"}
+	synonyms := map[string][]string{
+		"Dining":    {"restaurants", "take-away"},
+		"Transport": {"fares", "transit"},
+		"Travel":    {"flights"},
+	}
+	tests := []struct {
+		name      string
+		raw       string
+		threshold float64
+		want      string
+		score     float64
+	}{
+		{"exact", "Groceries", 0, "Groceries", 1},
+		{"case and punctuation", "  GROCERIES! ", 0, "Groceries", 1},
+		{"singular", "Grocery", 0, "Groceries", 0.95},
+		{"synonym", "Restaurant", 0, "Dining", 1},
+		{"synonym with punctuation", "Take Away", 0, "Dining", 1},
+		{"synonym of a missing category", "flights", 0, "", 0},
+		{"fuzzy above threshold", "Transprt", 0, "Transport", 1 - 1.0/9},
+		{"fuzzy below threshold", "Trnsprt", 0, "", 1 - 2.0/9},
+		{"fuzzy with lower threshold", "Trnsprt", 0.7, "Transport", 1 - 2.0/9},
+		{"plural synonym", "transits", 0, "Transport", 1},
+		{"empty", " - ", 0, "", 0},
+	}
+
+	for _, tt := range tests {
+		r := NewCategoryResolver(synonyms, tt.threshold)
+		got, score := r.Resolve(categories, tt.raw)
+		if got != tt.want {
+			t.Errorf("%s: Resolve(%q) = %q, want %q", tt.name, tt.raw, got, tt.want)
+		}
+		if tt.want != "" || tt.score != 0 {
+			if diff := score - tt.score; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("%s: Resolve(%q) score = %v, want %v", tt.name, tt.raw, score, tt.score)
+			}
+		}
+	}
+}
+
+func TestCategoryResolverTies(t *testing.T) {
+	categories := []string{"Shopping", "Dining", "Travel", "Transport"}
+	synonyms := map[string][]string{
+		"Shopping":  {"cave"},
+		"Dining":    {"cafe"},
+		"Travel":    {"fare"},
+		"Transport": {"fare"},
+	}
+
+	// Map order changes between runs, so resolve many times over.
+	for i := 0; i < 50; i++ {
+		r := NewCategoryResolver(synonyms, 0.7)
+
+		// "cade" is as close to "cafe" as to "cave"; the first synonym in
+		// sorted order wins.
+		if got, _ := r.Resolve(categories, "cade"); got != "Dining" {
+			t.Fatalf("fuzzy tie resolved to %q, want Dining", got)
+		}
+		// A synonym listed twice belongs to the first category in sorted
+		// order.
+		if got, _ := r.Resolve(categories, "fare"); got != "Transport" {
+			t.Fatalf("shared synonym resolved to %q, want Transport", got)
+		}
+	}
+
+	// Equally close categories resolve to the first in the list.
+	r := NewCategoryResolver(nil, 0.7)
+	if got, _ := r.Resolve([]string{"Rent", "Tent"}, "Bent"); got != "Rent" {
+		t.Errorf("category tie resolved to %q, want Rent", got)
+	}
+}
+
+func TestValidateTransactions(t *testing.T) {
+	categories := []string{"Groceries", "Dining"}
+	r := NewCategoryResolver(map[string][]string{"Dining": {"restaurants"}}, 0)
+
+	transactions := []store.Transaction{
+		tx("2024-03-01", "Supermarket", "-52.10"),
+		tx("2024-03-02", "Bistro", "-18.00"),
+		tx("2024-03-03", "Bookshop", "-9.99"),
+	}
+	transactions[0].CategoryName = "grocery"
+	transactions[1].CategoryName = "Restaurants"
+	transactions[2].CategoryName = "Books"
+
+	// A category that cannot be placed is reported, so the model is asked
+	// to pick a listed one.
+	errs := validateTransactions(transactions, categories, r)
+	if len(errs) != 1 || errs[0].Index != 2 || !strings.Contains(errs[0].Problem, `"Books"`) {
+		t.Fatalf("validateTransactions = %v, want the Books category reported", errs)
+	}
+	for i, want := range []string{"Groceries", "Dining", ""} {
+		if got := transactions[i].CategoryName; got != want {
+			t.Errorf("transaction %d category = %q, want %q", i, got, want)
+		}
+	}
+
+	bad := []store.Transaction{
+		tx("03/04/2024", "Supermarket", "-52.10"),
+		tx("2024-03-05", "Supermarket", "0"),
+		tx("2024-03-06", " ", "-1.00"),
+	}
+	errs = validateTransactions(bad, categories, r)
+	if len(errs) != 3 {
+		t.Fatalf("got %d errors, want 3: %v", len(errs), errs)
+	}
+	for i, err := range errs {
+		if err.Index != i {
+			t.Errorf("error %d is for transaction %d", i, err.Index)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dylanewe/moni/internal/money"
+	"github.com/dylanewe/moni/internal/store"
+)
+
+const (
+	defaultChunkSize   = 12000
+	defaultConcurrency = 4
+	// chunkOverlapLines is the number of trailing lines of a chunk repeated
+	// at the start of the next one, so rows cut by a page break are seen whole.
+	chunkOverlapLines = 3
+)
+
+type chunk struct {
+	// FirstPage and LastPage are 1-based and inclusive.
+	FirstPage int
+	LastPage  int
+	Text      string
+	// Overlap is the text repeated from the end of the previous chunk.
+	Overlap string
+}
+
+// ChunkError reports a part of a statement that could not be parsed.
+type ChunkError struct {
+	Chunk     int
+	FirstPage int
+	LastPage  int
+	Err       error
+}
+
+func (e ChunkError) Error() string {
+	return fmt.Sprintf("chunk %d (pages %d-%d): %v", e.Chunk+1, e.FirstPage, e.LastPage, e.Err)
+}
+
+func (e ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// ChunkErrors is returned together with the transactions of the chunks that
+// did parse, so a partial import can still be reviewed.
+type ChunkErrors struct {
+	Total  int
+	Errors []ChunkError
+}
+
+func (e *ChunkErrors) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d of %d chunks failed: %s", len(e.Errors), e.Total, strings.Join(msgs, "; "))
+}
+
+// chunkDocument groups whole pages into chunks of at most size characters.
+// Pages larger than size are split on blank lines, falling back to single
+// lines, so a transaction row is never cut in half.
+func chunkDocument(doc Document, size int) []chunk {
+	var chunks []chunk
+	var current *chunk
+
+	flush := func() {
+		if current != nil && strings.TrimSpace(current.Text) != "" {
+			chunks = append(chunks, *current)
+		}
+		current = nil
+	}
+
+	for i, page := range doc.Pages {
+		pageNum := i + 1
+		for _, section := range splitSections(page, size) {
+			if current != nil && len(current.Text)+len(section) > size {
+				flush()
+			}
+			if current == nil {
+				current = &chunk{FirstPage: pageNum}
+				if n := len(chunks); n > 0 {
+					current.Overlap = lastLines(chunks[n-1].Text, chunkOverlapLines)
+					current.Text = current.Overlap
+				}
+			}
+			current.Text += section
+			current.LastPage = pageNum
+		}
+	}
+	flush()
+
+	return chunks
+}
+
+func splitSections(page string, size int) []string {
+	if len(page) <= size {
+		return []string{page}
+	}
+
+	var sections []string
+	var b strings.Builder
+	for _, para := range strings.SplitAfter(page, "\n\n") {
+		if b.Len() > 0 && b.Len()+len(para) > size {
+			sections = append(sections, b.String())
+			b.Reset()
+		}
+		if len(para) <= size {
+			b.WriteString(para)
+			continue
+		}
+
+		for _, line := range strings.SplitAfter(para, "\n") {
+			if b.Len() > 0 && b.Len()+len(line) > size {
+				sections = append(sections, b.String())
+				b.Reset()
+			}
+			b.WriteString(line)
+		}
+	}
+	if b.Len() > 0 {
+		sections = append(sections, b.String())
+	}
+
+	return sections
+}
+
+func lastLines(text string, n int) string {
+	lines := strings.SplitAfter(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "") + "\n"
+}
+
+// mergeChunks concatenates the transactions of each chunk in page order.
+// A transaction is dropped as a boundary duplicate when the previous chunk
+// returned the same one and its amount appears in the overlapping text.
+func mergeChunks(chunks []chunk, results [][]store.Transaction) []store.Transaction {
+	var merged []store.Transaction
+	for i, txs := range results {
+		if i == 0 || chunks[i].Overlap == "" {
+			merged = append(merged, txs...)
+			continue
+		}
+
+		seen := make(map[string]int)
+		for _, tx := range results[i-1] {
+			seen[boundaryKey(tx)]++
+		}
+
+		for _, tx := range txs {
+			key := boundaryKey(tx)
+			if seen[key] > 0 && overlapHasAmount(chunks[i].Overlap, tx.Amount) {
+				seen[key]--
+				continue
+			}
+			merged = append(merged, tx)
+		}
+	}
+
+	return merged
+}
+
+func boundaryKey(tx store.Transaction) string {
+	return fmt.Sprintf("%s|%s|%s", tx.Date, tx.Amount.Decimal(), strings.ToLower(strings.Join(strings.Fields(tx.Description), " ")))
+}
+
+func overlapHasAmount(overlap string, amount money.Money) bool {
+	plain := amount.Abs().Decimal()
+	text := strings.ReplaceAll(overlap, ",", "")
+	return strings.Contains(text, plain)
+}
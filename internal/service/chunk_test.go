@@ -0,0 +1,260 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dylanewe/moni/internal/store"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+func TestChunkDocument(t *testing.T) {
+	doc := Document{Pages: []string{
+		"p1 a\np1 b\n",
+		"p2 a\np2 b\n",
+		"p3 a\np3 b\np3 c\np3 d\n",
+		"",
+		"p5 a\n",
+	}}
+
+	chunks := chunkDocument(doc, 30)
+	want := []struct {
+		first, last int
+		overlap     string
+	}{
+		{1, 2, ""},
+		{3, 3, "p1 b\np2 a\np2 b\n"},
+		{4, 5, "p3 b\np3 c\np3 d\n"},
+	}
+	if len(chunks) != len(want) {
+		t.Fatalf("got %d chunks, want %d: %+v", len(chunks), len(want), chunks)
+	}
+	for i, w := range want {
+		c := chunks[i]
+		if c.FirstPage != w.first || c.LastPage != w.last || c.Overlap != w.overlap {
+			t.Errorf("chunk %d = pages %d-%d overlap %q, want pages %d-%d overlap %q", i, c.FirstPage, c.LastPage, c.Overlap, w.first, w.last, w.overlap)
+		}
+		if !strings.HasPrefix(c.Text, c.Overlap) {
+			t.Errorf("chunk %d does not start with its overlap: %q", i, c.Text)
+		}
+	}
+	if chunks[0].Text != doc.Pages[0]+doc.Pages[1] {
+		t.Errorf("chunk 0 = %q, want pages 1 and 2 whole", chunks[0].Text)
+	}
+}
+
+func TestChunkDocumentSplitsLargePages(t *testing.T) {
+	page := "2024-03-01 Cafe 4.50\n2024-03-01 Bakery 3.20\n\n2024-03-02 Books 12.00\n2024-03-02 Rent 900.00\n2024-03-03 Fuel 60.00\n"
+	chunks := chunkDocument(Document{Pages: []string{page}}, 50)
+	if len(chunks) < 2 {
+		t.Fatalf("got %d chunks, want the page split", len(chunks))
+	}
+
+	var text string
+	for i, c := range chunks {
+		if c.FirstPage != 1 || c.LastPage != 1 {
+			t.Errorf("chunk %d = pages %d-%d, want 1-1", i, c.FirstPage, c.LastPage)
+		}
+		body := strings.TrimPrefix(c.Text, c.Overlap)
+		if !strings.HasSuffix(body, "\n") {
+			t.Errorf("chunk %d cuts a line: %q", i, body)
+		}
+		text += body
+	}
+	if text != page {
+		t.Errorf("chunks without overlap = %q, want the page", text)
+	}
+}
+
+func TestMergeChunks(t *testing.T) {
+	chunks := []chunk{
+		{Text: "2024-03-01 Cafe 4.50\n2024-03-02 Rent 900.00\n"},
+		{Overlap: "2024-03-02 Rent 900.00\n", Text: "2024-03-02 Rent 900.00\n2024-03-02 Rent 900.00\n"},
+		{Text: "2024-03-05 Books 12.00\n"},
+	}
+	results := [][]store.Transaction{
+		{tx("2024-03-01", "Cafe", "-4.50"), tx("2024-03-02", "Rent", "-900.00")},
+		// The first rent is the row repeated from the previous chunk, the
+		// second one a real second payment.
+		{tx("2024-03-02", "RENT", "-900.00"), tx("2024-03-02", "Rent", "-900.00")},
+		// Without overlap nothing is dropped, even an exact repeat.
+		{tx("2024-03-02", "Rent", "-900.00"), tx("2024-03-05", "Books", "-12.00")},
+	}
+
+	var got []string
+	for _, tx := range mergeChunks(chunks, results) {
+		got = append(got, tx.Date+" "+tx.Description)
+	}
+	want := []string{"2024-03-01 Cafe", "2024-03-02 Rent", "2024-03-02 Rent", "2024-03-02 Rent", "2024-03-05 Books"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("merged\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+type pagesExtractor []string
+
+func (e pagesExtractor) ExtractText(ctx context.Context, file string) (Document, error) {
+	return Document{Pages: e}, nil
+}
+
+// chatServer answers chat completions with one transaction per
+// "date description amount [category]" line of the document text. Text
+// containing "FAIL" is rejected, and text containing "SLOW" is answered
+// last. Categories are left out when the model is asked for a repair.
+func chatServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		text := req.Messages[1].Content
+		repair := len(req.Messages) > 2
+		if strings.Contains(text, "FAIL") {
+			http.Error(w, `{"error":{"message":"cannot read chunk"}}`, http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(text, "SLOW") {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		var transactions []map[string]any
+		for _, line := range strings.Split(text, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 && len(fields) != 4 {
+				continue
+			}
+			category := ""
+			if len(fields) == 4 && !repair {
+				category = fields[3]
+			}
+			transactions = append(transactions, map[string]any{
+				"date": fields[0], "description": fields[1], "amount": fields[2], "category": category,
+			})
+		}
+		content, _ := json.Marshal(map[string]any{"transactions": transactions})
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":     "chatcmpl-test",
+			"object": "chat.completion",
+			"model":  "test",
+			"choices": []map[string]any{{
+				"index":         0,
+				"finish_reason": "stop",
+				"message":       map[string]any{"role": "assistant", "content": string(content)},
+			}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestLLMParser(t *testing.T, pages ...string) *LLMParserService {
+	srv := chatServer(t)
+	client := openai.NewClient(option.WithBaseURL(srv.URL), option.WithAPIKey("test"), option.WithMaxRetries(0))
+	return &LLMParserService{
+		client:      &client,
+		extractor:   pagesExtractor(pages),
+		model:       "test",
+		chunkSize:   80,
+		concurrency: 4,
+		resolver:    NewCategoryResolver(nil, 0),
+	}
+}
+
+func TestLLMParserMergesInPageOrder(t *testing.T) {
+	// The first page is answered last, but its transactions still come
+	// first. Each page is a chunk of its own and the marker line is not
+	// repeated in the overlap.
+	s := newTestLLMParser(t,
+		"SLOW\n2024-03-01 Cafe -4.50\n2024-03-01 Tea -2.00\n2024-03-01 Bun -1.00\n",
+		"2024-03-02 Bakery -3.20\n",
+		"2024-03-03 Books -12.00\n",
+		"2024-03-04 Salary 2500.00\n",
+	)
+
+	transactions, err := s.ParseStatement(context.Background(), nil, "statement.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, tx := range transactions {
+		got = append(got, fmt.Sprintf("%s %s", tx.Date, tx.Description))
+	}
+	want := []string{"2024-03-01 Cafe", "2024-03-01 Tea", "2024-03-01 Bun", "2024-03-02 Bakery", "2024-03-03 Books", "2024-03-04 Salary"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLLMParserPartialFailure(t *testing.T) {
+	s := newTestLLMParser(t,
+		"2024-03-01 Cafe -4.50\n",
+		"FAIL\n2024-03-02 Bakery -3.20\n2024-03-02 Tea -2.00\n2024-03-02 Bun -1.00\n",
+		"2024-03-03 Books -12.00\n",
+	)
+
+	transactions, err := s.ParseStatement(context.Background(), nil, "statement.pdf")
+	var report *ChunkErrors
+	if !errors.As(err, &report) {
+		t.Fatalf("err = %v, want *ChunkErrors", err)
+	}
+	if report.Total != 3 || len(report.Errors) != 1 {
+		t.Fatalf("report = %v, want 1 of 3 chunks failed", report)
+	}
+	if e := report.Errors[0]; e.Chunk != 1 || e.FirstPage != 2 || e.LastPage != 2 {
+		t.Errorf("failed chunk = %+v, want chunk 1 on page 2", e)
+	}
+	// The rows of the failed chunk repeated in the next one's overlap are
+	// kept, as nothing returned them before.
+	var got []string
+	for _, tx := range transactions {
+		got = append(got, tx.Description)
+	}
+	if want := "Cafe Bakery Tea Bun Books"; strings.Join(got, " ") != want {
+		t.Errorf("transactions = %s, want %s", strings.Join(got, " "), want)
+	}
+
+	// When every chunk fails there is nothing to review.
+	s = newTestLLMParser(t, "FAIL\n", "FAIL\n")
+	s.chunkSize = 4
+	transactions, err = s.ParseStatement(context.Background(), nil, "statement.pdf")
+	if !errors.As(err, &report) || report.Total != 2 || len(report.Errors) != 2 || transactions != nil {
+		t.Errorf("got %v, %v; want no transactions and 2 of 2 chunks failed", transactions, err)
+	}
+}
+
+func TestLLMParserRepairsCategories(t *testing.T) {
+	// The model first answers with a category that is not listed and drops
+	// every category when asked for a repair.
+	s := newTestLLMParser(t, "2024-03-01 Cafe -4.50 Dining\n2024-03-02 Bookshop -9.99 Books\n")
+	s.repairAttempts = 1
+
+	transactions, err := s.ParseStatement(context.Background(), []string{"Dining"}, "statement.pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transactions) != 2 || transactions[0].CategoryName != "" || transactions[1].CategoryName != "" {
+		t.Errorf("transactions = %+v, want the repaired reply", transactions)
+	}
+
+	s.repairAttempts = 0
+	_, err = s.ParseStatement(context.Background(), []string{"Dining"}, "statement.pdf")
+	var report *ChunkErrors
+	if !errors.As(err, &report) || !strings.Contains(report.Error(), `"Books"`) {
+		t.Errorf("err = %v, want the Books category reported", err)
+	}
+}
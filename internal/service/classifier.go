@@ -0,0 +1,296 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/dylanewe/moni/internal/config"
+	"github.com/dylanewe/moni/internal/store"
+)
+
+const (
+	defaultClassifierPath          = "../classifier.json"
+	defaultClassifierMinConfidence = 0.7
+	defaultClassifierHoldout       = 0.2
+)
+
+// Classifier is a multinomial naive Bayes model over description words and
+// an amount bucket, trained on categorized transaction history. It runs
+// entirely locally.
+type Classifier struct {
+	Classes map[string]*ClassStats `json:"classes"`
+	// Vocabulary counts every feature seen in training across all classes.
+	Vocabulary map[string]int `json:"vocabulary"`
+	Documents  int            `json:"documents"`
+}
+
+type ClassStats struct {
+	Documents int            `json:"documents"`
+	Features  int            `json:"features"`
+	Counts    map[string]int `json:"counts"`
+}
+
+// Suggestion is the category the classifier predicts for a transaction.
+type Suggestion struct {
+	Category   string
+	Confidence float64
+}
+
+// TrainClassifier builds a model from transactions that have a category.
+func TrainClassifier(history []store.Transaction) *Classifier {
+	c := &Classifier{
+		Classes:    make(map[string]*ClassStats),
+		Vocabulary: make(map[string]int),
+	}
+	for _, tx := range history {
+		if tx.CategoryName == "" {
+			continue
+		}
+		class, ok := c.Classes[tx.CategoryName]
+		if !ok {
+			class = &ClassStats{Counts: make(map[string]int)}
+			c.Classes[tx.CategoryName] = class
+		}
+		class.Documents++
+		c.Documents++
+		for _, f := range classifierFeatures(tx) {
+			class.Counts[f]++
+			class.Features++
+			c.Vocabulary[f]++
+		}
+	}
+
+	return c
+}
+
+// Predict returns the most likely category for tx. The confidence is the
+// posterior probability of that category among all known ones.
+func (c *Classifier) Predict(tx store.Transaction) (Suggestion, bool) {
+	if c == nil || c.Documents == 0 {
+		return Suggestion{}, false
+	}
+
+	features := classifierFeatures(tx)
+	vocabulary := float64(len(c.Vocabulary))
+
+	categories := make([]string, 0, len(c.Classes))
+	for category := range c.Classes {
+		categories = append(categories, category)
+	}
+	// Ties go to the alphabetically first category so results are stable.
+	sort.Strings(categories)
+
+	scores := make([]float64, len(categories))
+	best := 0
+	for i, category := range categories {
+		class := c.Classes[category]
+		score := math.Log(float64(class.Documents) / float64(c.Documents))
+		for _, f := range features {
+			// Features never seen in training say nothing about the class.
+			if c.Vocabulary[f] == 0 {
+				continue
+			}
+			score += math.Log(float64(class.Counts[f]+1) / (float64(class.Features) + vocabulary))
+		}
+		scores[i] = score
+		if score > scores[best] {
+			best = i
+		}
+	}
+
+	var total float64
+	for _, score := range scores {
+		total += math.Exp(score - scores[best])
+	}
+
+	return Suggestion{Category: categories[best], Confidence: 1 / total}, true
+}
+
+// classifierFeatures turns a transaction into description words plus a
+// bucket for the sign and rough size of the amount.
+func classifierFeatures(tx store.Transaction) []string {
+	features := descriptionWords(tx.Description)
+	return append(features, amountBucket(tx.Amount.Float()))
+}
+
+// amountBucket groups amounts by half orders of magnitude, so 12.50 and
+// 25.00 share a bucket but 120.00 does not.
+func amountBucket(amount float64) string {
+	sign := "+"
+	if amount < 0 {
+		sign = "-"
+	}
+	a := math.Abs(amount)
+	if a < 1 {
+		return "amount:" + sign + "0"
+	}
+	return fmt.Sprintf("amount:%s%d", sign, int(math.Floor(2*math.Log10(a)))+1)
+}
+
+// Evaluation reports how a model trained on older history predicts the most
+// recent, held-out part of it.
+type Evaluation struct {
+	Train, Test int
+	Correct     int
+	// Confident and ConfidentCorrect only count predictions at or above the
+	// configured minimum confidence, i.e. the ones that would be applied.
+	Confident, ConfidentCorrect int
+}
+
+func (e Evaluation) Accuracy() float64 {
+	return ratio(e.Correct, e.Test)
+}
+
+func (e Evaluation) ConfidentAccuracy() float64 {
+	return ratio(e.ConfidentCorrect, e.Confident)
+}
+
+func (e Evaluation) Coverage() float64 {
+	return ratio(e.Confident, e.Test)
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// LocalCategorizer suggests categories from a classifier trained on the
+// user's own history and stored next to the config.
+type LocalCategorizer struct {
+	path          string
+	mode          string
+	minConfidence float64
+	holdout       float64
+}
+
+func NewLocalCategorizer(cfg config.ClassifierConfig) (*LocalCategorizer, error) {
+	path := cfg.ModelPath
+	if path == "" {
+		path = defaultClassifierPath
+	}
+	mode := cfg.Mode
+	switch mode {
+	case "":
+		mode = "fallback"
+	case "fallback", "replace", "off":
+	default:
+		return nil, fmt.Errorf("unknown classifier mode %q (want fallback, replace or off)", mode)
+	}
+	minConfidence := cfg.MinConfidence
+	if minConfidence <= 0 || minConfidence > 1 {
+		minConfidence = defaultClassifierMinConfidence
+	}
+	holdout := cfg.Holdout
+	if holdout <= 0 || holdout >= 1 {
+		holdout = defaultClassifierHoldout
+	}
+
+	return &LocalCategorizer{path: path, mode: mode, minConfidence: minConfidence, holdout: holdout}, nil
+}
+
+// Load reads the trained model. It returns nil without an error when the
+// classifier is off or has not been trained yet.
+func (l *LocalCategorizer) Load() (*Classifier, error) {
+	if l.mode == "off" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c Classifier
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("invalid classifier model %s: %w", l.path, err)
+	}
+	return &c, nil
+}
+
+// Suggest returns the categories to apply by index. In "fallback" mode only
+// transactions the parser left uncategorized get a suggestion; in "replace"
+// mode the classifier overrides the parser. Low-confidence predictions are
+// never returned.
+func (l *LocalCategorizer) Suggest(transactions []store.Transaction) (map[int]Suggestion, error) {
+	c, err := l.Load()
+	if err != nil || c == nil {
+		return nil, err
+	}
+
+	suggestions := make(map[int]Suggestion)
+	for i, tx := range transactions {
+		if l.mode != "replace" && tx.CategoryName != "" {
+			continue
+		}
+		if s, ok := c.Predict(tx); ok && s.Confidence >= l.minConfidence {
+			suggestions[i] = s
+		}
+	}
+	return suggestions, nil
+}
+
+// Retrain evaluates a model trained on all but the most recent share of
+// history, then trains on all of it and saves the result.
+func (l *LocalCategorizer) Retrain(history []store.Transaction) (Evaluation, error) {
+	sorted := make([]store.Transaction, 0, len(history))
+	for _, tx := range history {
+		if tx.CategoryName != "" {
+			sorted = append(sorted, tx)
+		}
+	}
+	if len(sorted) == 0 {
+		return Evaluation{}, errors.New("no categorized transactions to train on")
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Date < sorted[j].Date
+	})
+
+	split := len(sorted) - int(math.Round(float64(len(sorted))*l.holdout))
+	train, test := sorted[:split], sorted[split:]
+
+	eval := Evaluation{Train: len(train), Test: len(test)}
+	model := TrainClassifier(train)
+	for _, tx := range test {
+		s, ok := model.Predict(tx)
+		if !ok {
+			continue
+		}
+		correct := s.Category == tx.CategoryName
+		if correct {
+			eval.Correct++
+		}
+		if s.Confidence >= l.minConfidence {
+			eval.Confident++
+			if correct {
+				eval.ConfidentCorrect++
+			}
+		}
+	}
+
+	data, err := json.Marshal(TrainClassifier(sorted))
+	if err != nil {
+		return eval, err
+	}
+	if err := os.WriteFile(l.path, data, 0o600); err != nil {
+		return eval, fmt.Errorf("failed to save classifier: %w", err)
+	}
+
+	return eval, nil
+}
+
+func (l *LocalCategorizer) Path() string {
+	return l.path
+}
+
+func (l *LocalCategorizer) MinConfidence() float64 {
+	return l.minConfidence
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dylanewe/moni/internal/config"
+	"github.com/dylanewe/moni/internal/store"
+)
+
+func categorized(date, description, amount, category string) store.Transaction {
+	t := tx(date, description, amount)
+	t.CategoryName = category
+	return t
+}
+
+var classifierHistory = []store.Transaction{
+	categorized("2024-01-02", "CORNER CAFE 1234", "-4.50", "Dining"),
+	categorized("2024-01-05", "Corner Cafe", "-3.80", "Dining"),
+	categorized("2024-01-09", "BISTRO CENTRAL", "-24.00", "Dining"),
+	categorized("2024-01-10", "FRESH MARKET", "-62.10", "Groceries"),
+	categorized("2024-01-17", "Fresh Market 0042", "-48.30", "Groceries"),
+	categorized("2024-01-24", "FRESH MARKET", "-71.95", "Groceries"),
+	categorized("2024-01-31", "ACME PAYROLL", "2500.00", "Income"),
+	tx("2024-02-01", "UNKNOWN SHOP", "-10.00"),
+	categorized("2024-02-03", "CORNER CAFE 1234", "-4.20", "Dining"),
+	categorized("2024-02-07", "FRESH MARKET", "-55.00", "Groceries"),
+}
+
+func TestClassifier(t *testing.T) {
+	c := TrainClassifier(classifierHistory)
+	if c.Documents != 9 || len(c.Classes) != 3 {
+		t.Fatalf("trained on %d documents in %d classes, want 9 in 3", c.Documents, len(c.Classes))
+	}
+	if c.Classes["Groceries"].Documents != 4 {
+		t.Errorf("groceries documents = %d, want 4", c.Classes["Groceries"].Documents)
+	}
+
+	tests := []struct {
+		description, amount, want string
+	}{
+		{"CORNER CAFE 9999", "-5.10", "Dining"},
+		{"Fresh Market", "-60.00", "Groceries"},
+		{"ACME PAYROLL MARCH", "2600.00", "Income"},
+	}
+	for _, tt := range tests {
+		s, ok := c.Predict(tx("2024-03-01", tt.description, tt.amount))
+		if !ok || s.Category != tt.want {
+			t.Errorf("Predict(%q) = %+v, %v, want %s", tt.description, s, ok, tt.want)
+		}
+		if s.Confidence <= 0.5 || s.Confidence > 1 {
+			t.Errorf("Predict(%q) confidence = %v", tt.description, s.Confidence)
+		}
+	}
+
+	// Nothing but unseen words leaves only the prior and the amount.
+	s, _ := c.Predict(tx("2024-03-01", "ZZZ", "-0.50"))
+	if s.Confidence >= 0.9 {
+		t.Errorf("unseen description predicted %+v with high confidence", s)
+	}
+
+	var empty *Classifier
+	if _, ok := empty.Predict(tx("2024-03-01", "CORNER CAFE", "-4.50")); ok {
+		t.Error("a nil classifier predicted a category")
+	}
+	if _, ok := TrainClassifier(nil).Predict(tx("2024-03-01", "CORNER CAFE", "-4.50")); ok {
+		t.Error("an untrained classifier predicted a category")
+	}
+}
+
+func TestAmountBucket(t *testing.T) {
+	tests := []struct {
+		amount float64
+		want   string
+	}{
+		{0.5, "amount:+0"},
+		{-0.5, "amount:-0"},
+		{1, "amount:+1"},
+		{12.5, "amount:+3"},
+		{25, "amount:+3"},
+		{120, "amount:+5"},
+		{-2500, "amount:-7"},
+	}
+	for _, tt := range tests {
+		if got := amountBucket(tt.amount); got != tt.want {
+			t.Errorf("amountBucket(%v) = %s, want %s", tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestLocalCategorizer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "classifier.json")
+	l, err := NewLocalCategorizer(config.ClassifierConfig{ModelPath: path, Holdout: 0.25})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is suggested before the first training.
+	if suggestions, err := l.Suggest([]store.Transaction{tx("2024-03-01", "CORNER CAFE", "-4.50")}); err != nil || len(suggestions) != 0 {
+		t.Fatalf("untrained Suggest = %v, %v", suggestions, err)
+	}
+
+	// The two most recent of the nine categorized transactions are held
+	// out; the one without a category is not used at all.
+	eval, err := l.Retrain(classifierHistory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eval.Train != 7 || eval.Test != 2 {
+		t.Errorf("trained on %d, tested on %d, want 7 and 2", eval.Train, eval.Test)
+	}
+	if eval.Correct != 2 || eval.Accuracy() != 1 {
+		t.Errorf("evaluation = %+v, want both held-out transactions correct", eval)
+	}
+	if eval.Confident > eval.Test || eval.ConfidentCorrect > eval.Confident || eval.Coverage() != float64(eval.Confident)/2 {
+		t.Errorf("evaluation = %+v", eval)
+	}
+
+	incoming := []store.Transaction{
+		tx("2024-03-01", "CORNER CAFE 1234", "-4.50"),
+		categorized("2024-03-02", "FRESH MARKET", "-50.00", "Dining"),
+	}
+	suggestions, err := l.Suggest(incoming)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 1 || suggestions[0].Category != "Dining" {
+		t.Errorf("fallback suggestions = %+v, want only the uncategorized transaction", suggestions)
+	}
+
+	l, _ = NewLocalCategorizer(config.ClassifierConfig{ModelPath: path, Mode: "replace"})
+	suggestions, _ = l.Suggest(incoming)
+	if suggestions[1].Category != "Groceries" {
+		t.Errorf("replace suggestions = %+v, want the parser overridden", suggestions)
+	}
+
+	l, _ = NewLocalCategorizer(config.ClassifierConfig{ModelPath: path, Mode: "off"})
+	if suggestions, _ := l.Suggest(incoming); len(suggestions) != 0 {
+		t.Errorf("off suggestions = %+v", suggestions)
+	}
+
+	if _, err := l.Retrain([]store.Transaction{tx("2024-03-01", "CORNER CAFE", "-4.50")}); err == nil {
+		t.Error("retrained without categorized transactions")
+	}
+}
+
+func TestLocalCategorizerModes(t *testing.T) {
+	for _, mode := range []string{"", "fallback", "replace", "off"} {
+		if _, err := NewLocalCategorizer(config.ClassifierConfig{Mode: mode}); err != nil {
+			t.Errorf("mode %q: %v", mode, err)
+		}
+	}
+	for _, mode := range []string{"Off", "disabled", "fallback "} {
+		if _, err := NewLocalCategorizer(config.ClassifierConfig{Mode: mode}); err == nil || !strings.Contains(err.Error(), "unknown classifier mode") {
+			t.Errorf("mode %q: err = %v", mode, err)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dylanewe/moni/internal/config"
+	"github.com/dylanewe/moni/internal/money"
+	"github.com/dylanewe/moni/internal/store"
+)
+
+const dateLayout = "2006-01-02"
+
+var ErrNoBankProfile = errors.New("no bank profile matches this csv file")
+
+type CSVParserService struct {
+	banks []config.BankProfile
+}
+
+func (s *CSVParserService) Name() string {
+	return "csv"
+}
+
+func (s *CSVParserService) ParseStatement(ctx context.Context, categories []string, file string) ([]store.Transaction, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, reader, header := s.matchProfile(file, data)
+	if profile == nil {
+		return nil, ErrNoBankProfile
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[normalizeColumn(name)] = i
+	}
+
+	var transactions []store.Transaction
+	for line := 1; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+
+		tx, err := parseCSVRecord(profile, columns, record)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", line, err)
+		}
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
+
+// matchProfile finds the bank profile whose columns are present in the file
+// header, preferring profiles whose file pattern matches the file name. It
+// returns a reader positioned after the header row.
+func (s *CSVParserService) matchProfile(file string, data []byte) (*config.BankProfile, *csv.Reader, []string) {
+	name := strings.ToLower(filepath.Base(file))
+
+	var candidates []*config.BankProfile
+	for i := range s.banks {
+		p := &s.banks[i]
+		if p.FilePattern == "" {
+			continue
+		}
+		if ok, _ := filepath.Match(strings.ToLower(p.FilePattern), name); ok {
+			candidates = append(candidates, p)
+		}
+	}
+	for i := range s.banks {
+		if s.banks[i].FilePattern == "" {
+			candidates = append(candidates, &s.banks[i])
+		}
+	}
+
+	for _, p := range candidates {
+		reader := newCSVReader(skipLines(data, p.SkipRows), p.Delimiter)
+		header, err := reader.Read()
+		if err != nil || !hasColumns(p, header) {
+			continue
+		}
+		return p, reader, header
+	}
+
+	return nil, nil, nil
+}
+
+func newCSVReader(data []byte, delimiter string) *csv.Reader {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if delimiter != "" {
+		reader.Comma = []rune(delimiter)[0]
+	}
+	return reader
+}
+
+// skipLines drops the first n raw lines, such as the account summary some
+// banks put above the header row.
+func skipLines(data []byte, n int) []byte {
+	for range n {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return nil
+		}
+		data = data[i+1:]
+	}
+	return data
+}
+
+func hasColumns(p *config.BankProfile, header []string) bool {
+	present := make(map[string]bool, len(header))
+	for _, h := range header {
+		present[normalizeColumn(h)] = true
+	}
+
+	required := []string{p.DateColumn, p.DescriptionColumn}
+	if p.AmountColumn != "" {
+		required = append(required, p.AmountColumn)
+	} else {
+		required = append(required, p.DebitColumn, p.CreditColumn)
+	}
+
+	for _, col := range required {
+		if col == "" || !present[normalizeColumn(col)] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseCSVRecord(p *config.BankProfile, columns map[string]int, record []string) (store.Transaction, error) {
+	field := func(name string) string {
+		i, ok := columns[normalizeColumn(name)]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	layout := p.DateFormat
+	if layout == "" {
+		layout = dateLayout
+	}
+	date, err := time.Parse(layout, field(p.DateColumn))
+	if err != nil {
+		return store.Transaction{}, fmt.Errorf("invalid date %q: %w", field(p.DateColumn), err)
+	}
+
+	var amount money.Money
+	if p.AmountColumn != "" {
+		amount, err = parseAmount(field(p.AmountColumn), p.DecimalSeparator)
+		if err != nil {
+			return store.Transaction{}, err
+		}
+	} else {
+		debit, err := parseAmount(field(p.DebitColumn), p.DecimalSeparator)
+		if err != nil {
+			return store.Transaction{}, err
+		}
+		credit, err := parseAmount(field(p.CreditColumn), p.DecimalSeparator)
+		if err != nil {
+			return store.Transaction{}, err
+		}
+		amount = credit.Sub(debit)
+	}
+
+	return store.Transaction{
+		Description: field(p.DescriptionColumn),
+		Amount:      amount,
+		Date:        date.Format(dateLayout),
+		Account:     p.Name,
+	}, nil
+}
+
+// parseAmount reads a bank-formatted number such as "1.234,56", "(12.00)" or
+// "-$5.00". An empty value is treated as zero.
+func parseAmount(value, decimalSeparator string) (money.Money, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return money.Money{}, nil
+	}
+
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = s[1 : len(s)-1]
+	}
+	if strings.HasSuffix(s, "-") {
+		negative = true
+		s = strings.TrimSuffix(s, "-")
+	}
+
+	if decimalSeparator == "" {
+		decimalSeparator = "."
+	}
+
+	// Thousands separators, spaces and currency symbols are dropped.
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '-':
+			negative = !negative
+		case string(r) == decimalSeparator:
+			b.WriteRune('.')
+		}
+	}
+
+	amount, err := money.Parse(b.String())
+	if err != nil {
+		return money.Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+	if negative {
+		amount = amount.Neg()
+	}
+
+	return amount, nil
+}
+
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+}
+
+func isBlankRecord(record []string) bool {
+	for _, f := range record {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
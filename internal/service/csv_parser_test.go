@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dylanewe/moni/internal/config"
+)
+
+var testBanks = []config.BankProfile{
+	{
+		Name:              "Debit Bank",
+		FilePattern:       "debit-*.csv",
+		Delimiter:         ";",
+		SkipRows:          2,
+		DateColumn:        "Booking Date",
+		DescriptionColumn: "Text",
+		DebitColumn:       "Debit",
+		CreditColumn:      "Credit",
+		DateFormat:        "02.01.2006",
+		DecimalSeparator:  ",",
+	},
+	{
+		Name:              "US Bank",
+		DateColumn:        "Date",
+		DescriptionColumn: "Description",
+		AmountColumn:      "Amount",
+		DateFormat:        "01/02/2006",
+	},
+	{
+		Name:              "Plain Bank",
+		DateColumn:        "Date",
+		DescriptionColumn: "Payee",
+		AmountColumn:      "Amount",
+	},
+}
+
+func TestCSVParser(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		// want lists "date description amount account" per transaction.
+		want []string
+		err  string
+	}{
+		{
+			name: "amount column with US dates",
+			file: "export.csv",
+			content: "Date,Description,Amount\n" +
+				"03/01/2024,Corner Cafe,-4.50\n" +
+				"03/02/2024,\"ACME, Inc. payroll\",\"$2,500.00\"\n" +
+				"\n" +
+				"03/03/2024,Refund,(12.00)\n",
+			want: []string{
+				"2024-03-01 Corner Cafe -4.50 US Bank",
+				"2024-03-02 ACME, Inc. payroll 2500.00 US Bank",
+				"2024-03-03 Refund -12.00 US Bank",
+			},
+		},
+		{
+			name: "debit and credit columns after a preamble",
+			file: "debit-march.csv",
+			content: "Account;DE00 1234\n" +
+				"Period;March\n" +
+				"Booking Date;Text;Debit;Credit\n" +
+				"01.03.2024;Supermarkt;1.234,56;\n" +
+				"02.03.2024;Gehalt;;3.000,00\n" +
+				"03.03.2024;Storno;12,00-;\n" +
+				"04.03.2024;Rückbuchung;;5,00-\n",
+			want: []string{
+				"2024-03-01 Supermarkt -1234.56 Debit Bank",
+				"2024-03-02 Gehalt 3000.00 Debit Bank",
+				"2024-03-03 Storno 12.00 Debit Bank",
+				"2024-03-04 Rückbuchung -5.00 Debit Bank",
+			},
+		},
+		{
+			name:    "header with BOM and different case",
+			file:    "export.csv",
+			content: "\ufeffdate,PAYEE,amount\n2024-03-05,Bakery,-3.20\n",
+			want:    []string{"2024-03-05 Bakery -3.20 Plain Bank"},
+		},
+		{
+			name:    "file pattern does not match",
+			file:    "other.csv",
+			content: "Booking Date;Text;Debit;Credit\n01.03.2024;Supermarkt;1,00;\n",
+			err:     ErrNoBankProfile.Error(),
+		},
+		{
+			name:    "unknown columns",
+			file:    "export.csv",
+			content: "When,What,How much\n2024-03-05,Bakery,-3.20\n",
+			err:     ErrNoBankProfile.Error(),
+		},
+		{
+			name:    "invalid date",
+			file:    "export.csv",
+			content: "Date,Description,Amount\n03/01/2024,Cafe,-4.50\n2024-03-02,Cafe,-4.50\n",
+			err:     `row 2: invalid date "2024-03-02"`,
+		},
+		{
+			name:    "invalid amount",
+			file:    "export.csv",
+			content: "Date,Description,Amount\n03/01/2024,Cafe,n/a\n",
+			err:     `row 1: invalid amount "n/a"`,
+		},
+	}
+
+	s := &CSVParserService{banks: testBanks}
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), tt.file)
+		if err := os.WriteFile(file, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		transactions, err := s.ParseStatement(context.Background(), nil, file)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: err = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+
+		var got []string
+		for _, tx := range transactions {
+			got = append(got, strings.Join([]string{tx.Date, tx.Description, tx.Amount.String(), tx.Account}, " "))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
+
+func TestCSVParserNoProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "export.csv")
+	if err := os.WriteFile(file, []byte("Date,Description,Amount\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := &CSVParserService{}
+	if _, err := s.ParseStatement(context.Background(), nil, file); !errors.Is(err, ErrNoBankProfile) {
+		t.Errorf("err = %v, want ErrNoBankProfile", err)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value, separator, want string
+	}{
+		{"1,234.56", "", "1234.56"},
+		{"1.234,56", ",", "1234.56"},
+		{"-$5.00", "", "-5.00"},
+		{"(12.00)", "", "-12.00"},
+		{"12,00-", ",", "-12.00"},
+		{"1 000,5", ",", "1000.50"},
+		{"", "", "0.00"},
+	}
+	for _, tt := range tests {
+		got, err := parseAmount(tt.value, tt.separator)
+		if err != nil {
+			t.Errorf("parseAmount(%q): %v", tt.value, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("parseAmount(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
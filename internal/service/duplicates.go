@@ -0,0 +1,138 @@
+package service
+
+import (
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/dylanewe/moni/internal/config"
+	"github.com/dylanewe/moni/internal/store"
+)
+
+const (
+	defaultDuplicateWindowDays = 3
+	defaultDuplicateThreshold  = 0.5
+)
+
+// DuplicateFinder flags incoming transactions that are probably already
+// stored, e.g. because statements overlap or a card purchase shows up on both
+// the card and the bank statement.
+type DuplicateFinder struct {
+	// WindowDays is how far apart the dates of two duplicates may be.
+	WindowDays int
+	// Threshold is the minimum description similarity between 0 and 1.
+	Threshold float64
+}
+
+func NewDuplicateFinder(cfg config.DedupeConfig) DuplicateFinder {
+	windowDays := defaultDuplicateWindowDays
+	if cfg.WindowDays != nil {
+		windowDays = max(*cfg.WindowDays, 0)
+	}
+	threshold := cfg.DescriptionThreshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultDuplicateThreshold
+	}
+	return DuplicateFinder{WindowDays: windowDays, Threshold: threshold}
+}
+
+// Range returns the date range, padded by the window, that existing
+// transactions must be loaded for.
+func (f DuplicateFinder) Range(incoming []store.Transaction) (string, string, bool) {
+	var first, last time.Time
+	for _, tx := range incoming {
+		d, err := time.Parse(dateLayout, tx.Date)
+		if err != nil {
+			continue
+		}
+		if first.IsZero() || d.Before(first) {
+			first = d
+		}
+		if last.IsZero() || d.After(last) {
+			last = d
+		}
+	}
+	if first.IsZero() {
+		return "", "", false
+	}
+
+	return first.AddDate(0, 0, -f.WindowDays).Format(dateLayout),
+		last.AddDate(0, 0, f.WindowDays).Format(dateLayout), true
+}
+
+// Find returns, by index into incoming, the existing transaction each
+// likely duplicate matches. An existing transaction is matched at most once.
+func (f DuplicateFinder) Find(incoming, existing []store.Transaction) map[int]store.Transaction {
+	matches := make(map[int]store.Transaction)
+	used := make(map[int]bool)
+
+	for i, tx := range incoming {
+		date, err := time.Parse(dateLayout, tx.Date)
+		if err != nil {
+			continue
+		}
+
+		best, bestScore := -1, 0.0
+		for j, ex := range existing {
+			if used[j] || ex.Amount.Cents != tx.Amount.Cents {
+				continue
+			}
+			exDate, err := time.Parse(dateLayout, ex.Date)
+			if err != nil {
+				continue
+			}
+			if days := math.Abs(date.Sub(exDate).Hours() / 24); days > float64(f.WindowDays) {
+				continue
+			}
+
+			if score := descriptionSimilarity(tx.Description, ex.Description); score >= f.Threshold && score > bestScore {
+				best, bestScore = j, score
+			}
+		}
+
+		if best >= 0 {
+			used[best] = true
+			matches[i] = existing[best]
+		}
+	}
+
+	return matches
+}
+
+// descriptionSimilarity compares two descriptions ignoring case, digits and
+// punctuation, which usually hold reference numbers. The score is the better
+// of the edit-distance similarity and the share of common words.
+func descriptionSimilarity(a, b string) float64 {
+	wa, wb := descriptionWords(a), descriptionWords(b)
+	if len(wa) == 0 || len(wb) == 0 {
+		return 0
+	}
+
+	common := 0
+	for _, w := range wa {
+		for _, v := range wb {
+			if w == v || (len(w) >= 4 && strings.HasPrefix(v, w)) || (len(v) >= 4 && strings.HasPrefix(w, v)) {
+				common++
+				break
+			}
+		}
+	}
+	overlap := float64(common) / float64(min(len(wa), len(wb)))
+
+	return max(overlap, similarity(strings.Join(wa, " "), strings.Join(wb, " ")))
+}
+
+func descriptionWords(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	words := fields[:0]
+	for _, f := range fields {
+		if len(f) >= 3 {
+			words = append(words, f)
+		}
+	}
+	return words
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/dylanewe/moni/internal/config"
+	"github.com/dylanewe/moni/internal/money"
+	"github.com/dylanewe/moni/internal/store"
+)
+
+func tx(date, description, amount string) store.Transaction {
+	m, err := money.Parse(amount)
+	if err != nil {
+		panic(err)
+	}
+	return store.Transaction{Date: date, Description: description, Amount: m}
+}
+
+func TestDuplicateFinder(t *testing.T) {
+	existing := []store.Transaction{tx("2024-03-10", "CORNER CAFE #1234", "-4.50")}
+	tests := []struct {
+		name     string
+		incoming store.Transaction
+		want     bool
+	}{
+		{"same day", tx("2024-03-10", "Corner Cafe", "-4.50"), true},
+		{"edge of the window", tx("2024-03-13", "CORNER CAFE 5678", "-4.50"), true},
+		{"before the window", tx("2024-03-06", "CORNER CAFE", "-4.50"), false},
+		{"after the window", tx("2024-03-14", "CORNER CAFE", "-4.50"), false},
+		{"one cent off", tx("2024-03-10", "CORNER CAFE", "-4.51"), false},
+		{"opposite sign", tx("2024-03-10", "CORNER CAFE", "4.50"), false},
+		{"other merchant", tx("2024-03-10", "CITY TRAIN", "-4.50"), false},
+		{"bad date", tx("10/03/2024", "CORNER CAFE", "-4.50"), false},
+	}
+
+	f := NewDuplicateFinder(config.DedupeConfig{})
+	for _, tt := range tests {
+		matches := f.Find([]store.Transaction{tt.incoming}, existing)
+		if _, got := matches[0]; got != tt.want {
+			t.Errorf("%s: duplicate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDuplicateFinderMatchesOnce(t *testing.T) {
+	existing := []store.Transaction{tx("2024-03-10", "CORNER CAFE", "-4.50")}
+	incoming := []store.Transaction{
+		tx("2024-03-10", "CORNER CAFE", "-4.50"),
+		tx("2024-03-10", "CORNER CAFE", "-4.50"),
+	}
+	matches := NewDuplicateFinder(config.DedupeConfig{}).Find(incoming, existing)
+	if len(matches) != 1 {
+		t.Errorf("matched %d transactions against one stored transaction", len(matches))
+	}
+}
+
+func TestDuplicateFinderConfig(t *testing.T) {
+	zero := 0
+	tests := []struct {
+		cfg       config.DedupeConfig
+		window    int
+		threshold float64
+	}{
+		{config.DedupeConfig{}, defaultDuplicateWindowDays, defaultDuplicateThreshold},
+		{config.DedupeConfig{WindowDays: &zero, DescriptionThreshold: 0.8}, 0, 0.8},
+		{config.DedupeConfig{DescriptionThreshold: 1.5}, defaultDuplicateWindowDays, defaultDuplicateThreshold},
+	}
+	for _, tt := range tests {
+		f := NewDuplicateFinder(tt.cfg)
+		if f.WindowDays != tt.window || f.Threshold != tt.threshold {
+			t.Errorf("NewDuplicateFinder(%+v) = %+v", tt.cfg, f)
+		}
+	}
+
+	f := DuplicateFinder{WindowDays: 2}
+	start, end, ok := f.Range([]store.Transaction{tx("2024-03-10", "A", "1"), tx("2024-03-01", "B", "1")})
+	if !ok || start != "2024-02-28" || end != "2024-03-12" {
+		t.Errorf("Range = %s, %s, %v", start, end, ok)
+	}
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dylanewe/moni/internal/store"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/shared"
+)
+
+const defaultRepairAttempts = 2
+
+// ValidationError describes a single problem in the model's reply.
+type ValidationError struct {
+	// Index is the position of the offending transaction, or -1 when the
+	// reply as a whole could not be read.
+	Index   int
+	Problem string
+}
+
+func (e ValidationError) Error() string {
+	if e.Index < 0 {
+		return e.Problem
+	}
+	return fmt.Sprintf("transaction %d: %s", e.Index, e.Problem)
+}
+
+// ValidationErrors is returned when the model still produced invalid output
+// after all repair attempts.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "invalid model output: " + strings.Join(msgs, "; ")
+}
+
+type transactionsReply struct {
+	Transactions []store.Transaction `json:"transactions"`
+}
+
+// transactionsResponseFormat asks the model for a JSON object matching the
+// transaction schema. Strict mode only accepts an object at the root, so the
+// array is wrapped in a "transactions" property.
+func transactionsResponseFormat(categories []string) openai.ChatCompletionNewParamsResponseFormatUnion {
+	categoryEnum := append(slices.Clone(categories), "")
+
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"transactions": map[string]any{
+				"type": "array",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"description": map[string]any{"type": "string"},
+						"category":    map[string]any{"type": "string", "enum": categoryEnum},
+						"amount":      map[string]any{"type": "number"},
+						"date":        map[string]any{"type": "string", "pattern": `^\d{4}-\d{2}-\d{2}$`},
+					},
+					"required":             []string{"description", "category", "amount", "date"},
+					"additionalProperties": false,
+				},
+			},
+		},
+		"required":             []string{"transactions"},
+		"additionalProperties": false,
+	}
+
+	return openai.ChatCompletionNewParamsResponseFormatUnion{
+		OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+			JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+				Name:   "transactions",
+				Strict: openai.Bool(true),
+				Schema: schema,
+			},
+		},
+	}
+}
+
+// decodeTransactions reads the model's reply. Servers that ignore the
+// response format may still answer with a bare or fenced array, so both
+// shapes are accepted.
+func decodeTransactions(content string) ([]store.Transaction, error) {
+	content = strings.TrimSpace(content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+	content = strings.TrimSpace(content)
+
+	if strings.HasPrefix(content, "[") {
+		var transactions []store.Transaction
+		if err := json.Unmarshal([]byte(content), &transactions); err != nil {
+			return nil, err
+		}
+		return transactions, nil
+	}
+
+	var reply transactionsReply
+	if err := json.Unmarshal([]byte(content), &reply); err != nil {
+		return nil, err
+	}
+
+	return reply.Transactions, nil
+}
+
+// validateTransactions checks every transaction and maps its category onto
+// the canonical list in place. Bad dates and amounts, and categories the
+// resolver cannot place, are reported so the model gets a chance to fix
+// them.
+func validateTransactions(transactions []store.Transaction, categories []string, resolver *CategoryResolver) ValidationErrors {
+	var errs ValidationErrors
+	for i := range transactions {
+		tx := &transactions[i]
+		if strings.TrimSpace(tx.Description) == "" {
+			errs = append(errs, ValidationError{i, "description is empty"})
+		}
+		if _, err := time.Parse(dateLayout, tx.Date); err != nil {
+			errs = append(errs, ValidationError{i, fmt.Sprintf("date %q is not a valid YYYY-MM-DD date", tx.Date)})
+		}
+		if tx.Amount.IsZero() {
+			errs = append(errs, ValidationError{i, "amount must be a non-zero number"})
+		}
+		raw := tx.CategoryName
+		if tx.CategoryName, _ = resolver.Resolve(categories, raw); tx.CategoryName == "" && strings.TrimSpace(raw) != "" {
+			errs = append(errs, ValidationError{i, fmt.Sprintf(`category %q is not one of the listed categories, use one of them or ""`, raw)})
+		}
+	}
+
+	return errs
+}
+
+func repairPrompt(errs ValidationErrors) string {
+	var b strings.Builder
+	b.WriteString("Your previous reply could not be used because of these problems:\n")
+	for _, err := range errs {
+		b.WriteString("- ")
+		b.WriteString(err.Error())
+		b.WriteString("\n")
+	}
+	b.WriteString("\nReturn the complete corrected JSON for all transactions in the document, following the required schema.")
+
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package service
+
+import (
+	"strings"
+
+	"github.com/dylanewe/moni/internal/store"
+)
+
+// MerchantPattern reduces a description to the key used by merchant memory:
+// lowercase words of three letters or more, so reference numbers and dates
+// printed next to the merchant name do not matter.
+func MerchantPattern(description string) string {
+	return strings.Join(descriptionWords(description), " ")
+}
+
+// MerchantMemory recalls the categories the user picked for earlier
+// transactions with the same merchant pattern.
+type MerchantMemory struct {
+	byPattern map[string]store.Merchant
+}
+
+func NewMerchantMemory(merchants []store.Merchant) *MerchantMemory {
+	m := &MerchantMemory{byPattern: make(map[string]store.Merchant, len(merchants))}
+	for _, merchant := range merchants {
+		m.byPattern[merchant.Pattern] = merchant
+	}
+	return m
+}
+
+func (m *MerchantMemory) Lookup(tx store.Transaction) (store.Merchant, bool) {
+	pattern := MerchantPattern(tx.Description)
+	if pattern == "" {
+		return store.Merchant{}, false
+	}
+	merchant, ok := m.byPattern[pattern]
+	return merchant, ok
+}
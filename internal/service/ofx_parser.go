@@ -0,0 +1,162 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dylanewe/moni/internal/money"
+	"github.com/dylanewe/moni/internal/store"
+	"golang.org/x/text/encoding/charmap"
+)
+
+var ErrNotOFX = errors.New("file is not an ofx statement")
+
+// OFXParserService reads OFX 1.x (SGML) and 2.x (XML) statements, which
+// includes QFX files. Both versions are handled by the same tag scanner: SGML
+// leaf elements are not closed, so a leaf value is always the text up to the
+// next tag.
+type OFXParserService struct{}
+
+// ofxTransaction holds the leaf fields of a STMTTRN aggregate by tag name.
+type ofxTransaction map[string]string
+
+func (s *OFXParserService) Name() string {
+	return "ofx"
+}
+
+func (s *OFXParserService) ParseStatement(ctx context.Context, categories []string, file string) ([]store.Transaction, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	start := bytes.Index(bytes.ToUpper(data), []byte("<OFX>"))
+	if start < 0 {
+		return nil, ErrNotOFX
+	}
+
+	body := data[start:]
+	if !utf8.Valid(body) {
+		if body, err = charmap.Windows1252.NewDecoder().Bytes(body); err != nil {
+			return nil, err
+		}
+	}
+
+	var transactions []store.Transaction
+	for _, stmt := range scanOFXTransactions(string(body)) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		tx, err := stmt.toTransaction()
+		if err != nil {
+			return nil, fmt.Errorf("transaction %q: %w", stmt["FITID"], err)
+		}
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
+
+func scanOFXTransactions(body string) []ofxTransaction {
+	var transactions []ofxTransaction
+	var current ofxTransaction
+	var account, currency string
+
+	for {
+		open := strings.IndexByte(body, '<')
+		if open < 0 {
+			break
+		}
+		end := strings.IndexByte(body[open:], '>')
+		if end < 0 {
+			break
+		}
+
+		tag := strings.ToUpper(strings.TrimSpace(body[open+1 : open+end]))
+		body = body[open+end+1:]
+
+		value := body
+		if next := strings.IndexByte(body, '<'); next >= 0 {
+			value = body[:next]
+		}
+		value = html.UnescapeString(strings.TrimSpace(value))
+
+		switch {
+		case tag == "STMTTRN":
+			current = make(ofxTransaction)
+		case tag == "ACCTID" && current == nil:
+			account = value
+		case tag == "CURDEF":
+			currency = value
+		case tag == "/STMTTRN":
+			if current != nil {
+				current["ACCTID"] = account
+				current["CURDEF"] = currency
+				transactions = append(transactions, current)
+				current = nil
+			}
+		case current != nil && !strings.HasPrefix(tag, "/") && value != "":
+			// NAME can appear both directly and inside a PAYEE aggregate;
+			// the first one wins.
+			if _, ok := current[tag]; !ok {
+				current[tag] = value
+			}
+		}
+	}
+
+	return transactions
+}
+
+func (t ofxTransaction) toTransaction() (store.Transaction, error) {
+	date, err := parseOFXDate(t["DTPOSTED"])
+	if err != nil {
+		return store.Transaction{}, err
+	}
+
+	raw := t["TRNAMT"]
+	if !strings.Contains(raw, ".") {
+		raw = strings.Replace(raw, ",", ".", 1)
+	}
+	amount, err := money.Parse(raw)
+	if err != nil {
+		return store.Transaction{}, fmt.Errorf("invalid amount %q", t["TRNAMT"])
+	}
+
+	description := t["NAME"]
+	if memo := t["MEMO"]; description == "" {
+		description = memo
+	} else if memo != "" && !strings.Contains(description, memo) {
+		description = description + " " + memo
+	}
+
+	return store.Transaction{
+		Description: description,
+		Amount:      amount.In(t["CURDEF"]),
+		Date:        date,
+		FITID:       t["FITID"],
+		Account:     t["ACCTID"],
+	}, nil
+}
+
+// parseOFXDate reads the date part of an OFX datetime such as
+// "20260131120000.000[-5:EST]".
+func parseOFXDate(value string) (string, error) {
+	if len(value) < 8 {
+		return "", fmt.Errorf("invalid date %q", value)
+	}
+
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return "", fmt.Errorf("invalid date %q: %w", value, err)
+	}
+
+	return date.Format(dateLayout), nil
+}
@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dylanewe/moni/internal/money"
+	"github.com/dylanewe/moni/internal/store"
+)
+
+// sgmlStatement is an OFX 1.x statement as banks export it: a plain-text
+// header and leaf elements that are never closed.
+const sgmlStatement = `OFXHEADER:100
+DATA:OFXSGML
+VERSION:102
+ENCODING:USASCII
+CHARSET:1252
+
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0<SEVERITY>INFO</STATUS><DTSERVER>20240305120000</SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>1<STMTRS>
+<CURDEF>EUR
+<BANKACCTFROM>
+<BANKID>12345
+<ACCTID>DE0012345678
+<ACCTTYPE>CHECKING
+</BANKACCTFROM>
+<BANKTRANLIST>
+<DTSTART>20240301
+<DTEND>20240305
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240301120000.000[-5:EST]
+<TRNAMT>-4.50
+<FITID>2024030101
+<NAME>CORNER CAFE
+<MEMO>Card 1234
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT
+<DTPOSTED>20240302
+<TRNAMT>2500,00
+<FITID>2024030201
+<NAME>ACME PAYROLL
+<MEMO>ACME PAYROLL
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20240303
+<TRNAMT>-12.00
+<FITID>2024030301
+<MEMO>Tom &amp; Jerry&apos;s
+</STMTTRN>
+</BANKTRANLIST>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`
+
+// qfxStatement is an OFX 2.x statement as Quicken downloads it: XML with
+// closed elements, an Intuit extension and a payee aggregate.
+const qfxStatement = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+  <CREDITCARDMSGSRSV1>
+    <CCSTMTTRNRS>
+      <CCSTMTRS>
+        <CURDEF>usd</CURDEF>
+        <CCACCTFROM><ACCTID>4111111111111111</ACCTID></CCACCTFROM>
+        <BANKTRANLIST>
+          <STMTTRN>
+            <TRNTYPE>DEBIT</TRNTYPE>
+            <DTPOSTED>20240410</DTPOSTED>
+            <TRNAMT>-35.99</TRNAMT>
+            <FITID>A1</FITID>
+            <NAME>AMAZON MKTPLACE</NAME>
+            <PAYEE><NAME>Amazon</NAME></PAYEE>
+          </STMTTRN>
+        </BANKTRANLIST>
+      </CCSTMTRS>
+    </CCSTMTTRNRS>
+  </CREDITCARDMSGSRSV1>
+  <INTU.BID>1234</INTU.BID>
+</OFX>
+`
+
+func parseOFX(t *testing.T, name, content string) ([]store.Transaction, error) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := &OFXParserService{}
+	return s.ParseStatement(context.Background(), nil, file)
+}
+
+func TestOFXParser(t *testing.T) {
+	tests := []struct {
+		name, file, content string
+		want                []store.Transaction
+	}{
+		{
+			name:    "sgml",
+			file:    "statement.ofx",
+			content: sgmlStatement,
+			want: []store.Transaction{
+				{Date: "2024-03-01", Description: "CORNER CAFE Card 1234", Amount: money.New(-450, "EUR"), FITID: "2024030101", Account: "DE0012345678"},
+				{Date: "2024-03-02", Description: "ACME PAYROLL", Amount: money.New(250000, "EUR"), FITID: "2024030201", Account: "DE0012345678"},
+				{Date: "2024-03-03", Description: "Tom & Jerry's", Amount: money.New(-1200, "EUR"), FITID: "2024030301", Account: "DE0012345678"},
+			},
+		},
+		{
+			name:    "qfx",
+			file:    "download.qfx",
+			content: qfxStatement,
+			want: []store.Transaction{
+				{Date: "2024-04-10", Description: "AMAZON MKTPLACE", Amount: money.New(-3599, "USD"), FITID: "A1", Account: "4111111111111111"},
+			},
+		},
+		{
+			name:    "sgml with windows-1252 text",
+			file:    "statement.ofx",
+			content: strings.Replace(sgmlStatement, "CORNER CAFE", "CORNER CAF\xc9", 1),
+			want: []store.Transaction{
+				{Date: "2024-03-01", Description: "CORNER CAFÉ Card 1234", Amount: money.New(-450, "EUR"), FITID: "2024030101", Account: "DE0012345678"},
+				{Date: "2024-03-02", Description: "ACME PAYROLL", Amount: money.New(250000, "EUR"), FITID: "2024030201", Account: "DE0012345678"},
+				{Date: "2024-03-03", Description: "Tom & Jerry's", Amount: money.New(-1200, "EUR"), FITID: "2024030301", Account: "DE0012345678"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := parseOFX(t, tt.file, tt.content)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d transactions, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i, want := range tt.want {
+			g := got[i]
+			if g.Date != want.Date || g.Description != want.Description || g.Amount != want.Amount || g.FITID != want.FITID || g.Account != want.Account {
+				t.Errorf("%s: transaction %d = %+v, want %+v", tt.name, i, g, want)
+			}
+		}
+	}
+}
+
+func TestOFXParserErrors(t *testing.T) {
+	if _, err := parseOFX(t, "export.csv", "Date,Description,Amount\n"); !errors.Is(err, ErrNotOFX) {
+		t.Errorf("csv: err = %v, want ErrNotOFX", err)
+	}
+
+	bad := strings.Replace(sgmlStatement, "<DTPOSTED>20240302", "<DTPOSTED>0302", 1)
+	if _, err := parseOFX(t, "bad.ofx", bad); err == nil || !strings.Contains(err.Error(), `transaction "2024030201": invalid date`) {
+		t.Errorf("bad date: err = %v", err)
+	}
+
+	bad = strings.Replace(sgmlStatement, "<TRNAMT>-12.00", "<TRNAMT>twelve", 1)
+	if _, err := parseOFX(t, "bad.ofx", bad); err == nil || !strings.Contains(err.Error(), `invalid amount "twelve"`) {
+		t.Errorf("bad amount: err = %v", err)
+	}
+}
+
+func TestOFXParserUnclosedStatement(t *testing.T) {
+	// A statement cut off inside a transaction keeps the complete ones.
+	cut := sgmlStatement[:strings.Index(sgmlStatement, "<FITID>2024030201")]
+	got, err := parseOFX(t, "cut.ofx", cut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].FITID != "2024030101" {
+		t.Errorf("got %+v, want only the first transaction", got)
+	}
+}
@@ -1,23 +1,40 @@
 package service
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"os/exec"
 	"strings"
+	"sync"
 
 	"github.com/dylanewe/moni/internal/store"
 	"github.com/openai/openai-go/v3"
 )
 
 type LLMParserService struct {
-	client *openai.Client
+	client    *openai.Client
+	extractor TextExtractor
+	model     string
+	maxTokens int64
+	// local is set for OpenAI-compatible servers, which understand
+	// max_tokens but not always max_completion_tokens.
+	local          bool
+	chunkSize      int
+	concurrency    int
+	repairAttempts int
+	resolver       *CategoryResolver
+}
+
+func (s *LLMParserService) Name() string {
+	return "llm"
+}
+
+// Model is the chat model used to read statements.
+func (s *LLMParserService) Model() string {
+	return s.model
 }
 
 func (s *LLMParserService) ParseStatement(ctx context.Context, categories []string, filepath string) ([]store.Transaction, error) {
-	parsedData, err := extractPDFToText(ctx, filepath)
+	doc, err := s.extractor.ExtractText(ctx, filepath)
 	if err != nil {
 		return nil, err
 	}
@@ -27,6 +44,7 @@ func (s *LLMParserService) ParseStatement(ctx context.Context, categories []stri
 DOCUMENT TYPES:
 - Payslip: Extract ONLY the net salary (final take-home pay)
 - Bank/Credit statements: Extract all transactions
+The document text may be one part of a longer statement. Extract only the transactions it contains.
 
 EXTRACTION RULES:
 1. Amount signs:
@@ -45,65 +63,105 @@ AVAILABLE CATEGORIES:
 %s
 
 OUTPUT FORMAT:
-Return a valid JSON array of transactions. Each transaction must follow this exact structure:
+Return a JSON object with a "transactions" array. Each transaction must follow this exact structure:
 {
   "description": "string - merchant or transaction description",
   "category": "string - from available categories or empty",
-  "amount": float - negative for expenses, positive for income,
+  "amount": float - negative for expenses, positive for income, never zero,
   "date": "string - YYYY-MM-DD format"
 }
 
-Return ONLY the JSON array with no additional text or markdown formatting.
+Return ONLY the JSON object with no additional text or markdown formatting.
 
 DOCUMENT TEXT:
 `, strings.Join(categories, ", "))
 
-	chatCompletion, err := s.client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage(prompt),
-			openai.UserMessage(parsedData),
-		},
-		Model:       openai.ChatModelGPT4oMini,
-		Temperature: openai.Float(0),
-	})
-	if err != nil {
-		return nil, err
+	chunks := chunkDocument(doc, s.chunkSize)
+	results := make([][]store.Transaction, len(chunks))
+	errs := make([]error, len(chunks))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.concurrency)
+	for i, c := range chunks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			results[i], errs[i] = s.parseChunk(ctx, prompt, categories, c.Text)
+		}()
 	}
-
-	content := chatCompletion.Choices[0].Message.Content
-	content = strings.TrimSpace(content)
-	content = strings.TrimPrefix(content, "```json")
-	content = strings.TrimPrefix(content, "```")
-	content = strings.TrimSuffix(content, "```")
-	content = strings.TrimSpace(content)
-
-	var transactions []store.Transaction
-	if err := json.Unmarshal([]byte(content), &transactions); err != nil {
-		return nil, err
+	wg.Wait()
+
+	report := &ChunkErrors{Total: len(chunks)}
+	for i, err := range errs {
+		if err != nil {
+			report.Errors = append(report.Errors, ChunkError{
+				Chunk:     i,
+				FirstPage: chunks[i].FirstPage,
+				LastPage:  chunks[i].LastPage,
+				Err:       err,
+			})
+		}
+	}
+	if len(report.Errors) > 0 && len(report.Errors) == len(chunks) {
+		return nil, report
 	}
 
-	for _, tx := range transactions {
-		tx.CategoryName = strings.ToLower(tx.CategoryName)
+	transactions := mergeChunks(chunks, results)
+
+	if len(report.Errors) > 0 {
+		return transactions, report
 	}
 
 	return transactions, nil
 }
 
-func extractPDFToText(ctx context.Context, filepath string) (string, error) {
-	args := []string{
-		"-layout",
-		"-nopgbrk",
-		filepath,
-		"-",
+// parseChunk asks the model for the transactions in text. Replies that cannot
+// be decoded or fail validation are sent back to the model together with the
+// problems found, up to repairAttempts times.
+func (s *LLMParserService) parseChunk(ctx context.Context, prompt string, categories []string, text string) ([]store.Transaction, error) {
+	params := openai.ChatCompletionNewParams{
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(prompt),
+			openai.UserMessage(text),
+		},
+		Model:          s.model,
+		Temperature:    openai.Float(0),
+		ResponseFormat: transactionsResponseFormat(categories),
+	}
+	if s.maxTokens > 0 {
+		if s.local {
+			params.MaxTokens = openai.Int(s.maxTokens)
+		} else {
+			params.MaxCompletionTokens = openai.Int(s.maxTokens)
+		}
 	}
-	cmd := exec.CommandContext(ctx, "pdftotext", args...)
-
-	var buf bytes.Buffer
-	cmd.Stdout = &buf
 
-	if err := cmd.Run(); err != nil {
-		return "", err
+	var errs ValidationErrors
+	for attempt := 0; attempt <= s.repairAttempts; attempt++ {
+		chatCompletion, err := s.client.Chat.Completions.New(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		if len(chatCompletion.Choices) == 0 {
+			return nil, fmt.Errorf("model %s returned no choices", s.model)
+		}
+
+		content := chatCompletion.Choices[0].Message.Content
+		transactions, err := decodeTransactions(content)
+		if err != nil {
+			errs = ValidationErrors{{Index: -1, Problem: fmt.Sprintf("reply is not valid JSON: %v", err)}}
+		} else if errs = validateTransactions(transactions, categories, s.resolver); len(errs) == 0 {
+			return transactions, nil
+		}
+
+		params.Messages = append(params.Messages,
+			openai.AssistantMessage(content),
+			openai.UserMessage(repairPrompt(errs)),
+		)
 	}
 
-	return buf.String(), nil
+	return nil, errs
 }
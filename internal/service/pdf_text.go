@@ -0,0 +1,162 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+var (
+	ErrPDFEncrypted     = errors.New("pdf is encrypted")
+	ErrPDFImageOnly     = errors.New("pdf has no text layer (scanned or image-only)")
+	ErrPDFToTextMissing = errors.New("pdftotext is not installed")
+)
+
+// TextExtractor turns a PDF statement into plain text that keeps the column
+// layout of the original document.
+type TextExtractor interface {
+	ExtractText(ctx context.Context, file string) (Document, error)
+}
+
+// Document is the extracted text of a PDF, one entry per page.
+type Document struct {
+	Pages []string
+}
+
+func (d Document) Text() string {
+	return strings.Join(d.Pages, "\n")
+}
+
+// NewTextExtractor returns the extractor for the configured backend name.
+// The native Go backend is used unless "pdftotext" is requested.
+func NewTextExtractor(backend string) TextExtractor {
+	if backend == "pdftotext" {
+		return &PDFToTextExtractor{}
+	}
+	return &NativePDFExtractor{}
+}
+
+// NativePDFExtractor reads PDFs without any external tools. Glyphs are placed
+// on a character grid derived from their page coordinates, which mimics the
+// output of `pdftotext -layout`.
+type NativePDFExtractor struct{}
+
+func (e *NativePDFExtractor) ExtractText(ctx context.Context, file string) (Document, error) {
+	f, r, err := pdf.Open(file)
+	if err != nil {
+		if isEncryptionErr(err) {
+			return Document{}, fmt.Errorf("%s: %w", file, ErrPDFEncrypted)
+		}
+		return Document{}, err
+	}
+	defer f.Close()
+
+	var doc Document
+	hasText := false
+	for i := 1; i <= r.NumPage(); i++ {
+		if err := ctx.Err(); err != nil {
+			return Document{}, err
+		}
+
+		page := r.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		text, err := layoutPage(page)
+		if err != nil {
+			return Document{}, fmt.Errorf("page %d: %w", i, err)
+		}
+		if strings.TrimSpace(text) != "" {
+			hasText = true
+		}
+		doc.Pages = append(doc.Pages, text)
+	}
+
+	if !hasText {
+		return Document{}, fmt.Errorf("%s: %w", file, ErrPDFImageOnly)
+	}
+
+	return doc, nil
+}
+
+func isEncryptionErr(err error) bool {
+	return errors.Is(err, pdf.ErrInvalidPassword) || strings.Contains(err.Error(), "encryption")
+}
+
+func layoutPage(page pdf.Page) (text string, err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			err = fmt.Errorf("malformed page: %v", x)
+		}
+	}()
+
+	glyphs := page.Content().Text
+	if len(glyphs) == 0 {
+		return "", nil
+	}
+
+	charWidth := medianGlyphWidth(glyphs)
+
+	// Glyphs on the same baseline, give or take rounding, form one line.
+	lines := make(map[int][]pdf.Text)
+	for _, g := range glyphs {
+		y := int(math.Round(g.Y))
+		lines[y] = append(lines[y], g)
+	}
+
+	ys := make([]int, 0, len(lines))
+	for y := range lines {
+		ys = append(ys, y)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(ys)))
+
+	var b strings.Builder
+	for _, y := range ys {
+		line := lines[y]
+		slices.SortStableFunc(line, func(a, b pdf.Text) int {
+			return cmp.Compare(a.X, b.X)
+		})
+
+		var row []rune
+		lastEnd := math.Inf(-1)
+		for _, g := range line {
+			col := int(math.Round(g.X / charWidth))
+			if len(row) < col {
+				row = append(row, []rune(strings.Repeat(" ", col-len(row)))...)
+			} else if len(row) > 0 && row[len(row)-1] != ' ' && g.X-lastEnd > charWidth*0.3 {
+				// Keep words apart even when the grid has no room left.
+				row = append(row, ' ')
+			}
+			row = append(row, []rune(g.S)...)
+			lastEnd = g.X + g.W
+		}
+
+		b.WriteString(strings.TrimRight(string(row), " "))
+		b.WriteString("\n")
+	}
+
+	return b.String(), nil
+}
+
+func medianGlyphWidth(glyphs []pdf.Text) float64 {
+	widths := make([]float64, 0, len(glyphs))
+	for _, g := range glyphs {
+		if n := len([]rune(g.S)); n > 0 && g.W > 0 {
+			widths = append(widths, g.W/float64(n))
+		}
+	}
+	if len(widths) == 0 {
+		return 5
+	}
+
+	sort.Float64s(widths)
+	return widths[len(widths)/2]
+}
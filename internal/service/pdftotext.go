@@ -0,0 +1,46 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// PDFToTextExtractor shells out to poppler's pdftotext binary.
+type PDFToTextExtractor struct{}
+
+func (e *PDFToTextExtractor) ExtractText(ctx context.Context, file string) (Document, error) {
+	bin, err := exec.LookPath("pdftotext")
+	if err != nil {
+		return Document{}, ErrPDFToTextMissing
+	}
+
+	args := []string{
+		"-layout",
+		file,
+		"-",
+	}
+	cmd := exec.CommandContext(ctx, bin, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if strings.Contains(strings.ToLower(msg), "password") {
+			return Document{}, fmt.Errorf("%s: %w", file, ErrPDFEncrypted)
+		}
+		return Document{}, fmt.Errorf("pdftotext: %v: %s", err, msg)
+	}
+
+	// Pages are separated by form feeds, which -nopgbrk would drop.
+	pages := strings.Split(strings.TrimSuffix(stdout.String(), "\f"), "\f")
+	if strings.TrimSpace(strings.Join(pages, "")) == "" {
+		return Document{}, fmt.Errorf("%s: %w", file, ErrPDFImageOnly)
+	}
+
+	return Document{Pages: pages}, nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dylanewe/moni/internal/store"
+)
+
+// RuleEngine categorizes transactions from user-defined merchant rules. Rules
+// are tried in the order given and the first match wins.
+type RuleEngine struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	store.Rule
+	re *regexp.Regexp
+	// parts are the pieces of a substring pattern split on '*'; they must
+	// appear in the description in order.
+	parts []string
+}
+
+func NewRuleEngine(rules []store.Rule) (*RuleEngine, error) {
+	e := &RuleEngine{rules: make([]compiledRule, 0, len(rules))}
+	for _, r := range rules {
+		c := compiledRule{Rule: r}
+		if r.IsRegex {
+			re, err := regexp.Compile("(?i)" + r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+			}
+			c.re = re
+		} else {
+			for _, p := range strings.Split(strings.ToLower(r.Pattern), "*") {
+				if p != "" {
+					c.parts = append(c.parts, p)
+				}
+			}
+		}
+		e.rules = append(e.rules, c)
+	}
+
+	return e, nil
+}
+
+// Match returns the first rule that applies to tx.
+func (e *RuleEngine) Match(tx store.Transaction) (store.Rule, bool) {
+	for _, r := range e.rules {
+		if r.matches(tx) {
+			return r.Rule, true
+		}
+	}
+	return store.Rule{}, false
+}
+
+// MatchAll returns the matching rule for each transaction by index.
+func (e *RuleEngine) MatchAll(transactions []store.Transaction) map[int]store.Rule {
+	matches := make(map[int]store.Rule)
+	for i, tx := range transactions {
+		if r, ok := e.Match(tx); ok {
+			matches[i] = r
+		}
+	}
+	return matches
+}
+
+func (r compiledRule) matches(tx store.Transaction) bool {
+	if r.AccountID != 0 && r.AccountID != tx.AccountID {
+		return false
+	}
+
+	amount := tx.Amount.Abs().Cents
+	if r.MinAmount != nil && amount < r.MinAmount.Cents {
+		return false
+	}
+	if r.MaxAmount != nil && amount > r.MaxAmount.Cents {
+		return false
+	}
+
+	if r.re != nil {
+		return r.re.MatchString(tx.Description)
+	}
+
+	desc := strings.ToLower(tx.Description)
+	for _, p := range r.parts {
+		i := strings.Index(desc, p)
+		if i < 0 {
+			return false
+		}
+		desc = desc[i+len(p):]
+	}
+	return len(r.parts) > 0
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dylanewe/moni/internal/money"
+	"github.com/dylanewe/moni/internal/store"
+)
+
+func cents(c int64) *money.Money {
+	m := money.New(c, "")
+	return &m
+}
+
+func TestRuleEngine(t *testing.T) {
+	// Rules come from the store highest priority first.
+	rules := []store.Rule{
+		{Name: "uber eats", Pattern: "uber*eats", CategoryName: "Dining", Priority: 10},
+		{Name: "card fee", Pattern: "fee", AccountID: 2, CategoryName: "Fees", Priority: 5},
+		{Name: "big amazon", Pattern: "amazon", MinAmount: cents(10000), CategoryName: "Electronics", Priority: 5},
+		{Name: "small amazon", Pattern: "amazon", MaxAmount: cents(2000), CategoryName: "Books", Priority: 5},
+		{Name: "salary", Pattern: `^acme\s+(payroll|salary)$`, IsRegex: true, CategoryName: "Income", Priority: 1},
+		{Name: "uber", Pattern: "UBER", CategoryName: "Transport"},
+	}
+	e, err := NewRuleEngine(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		description string
+		amount      string
+		accountID   int64
+		want        string
+	}{
+		{"higher priority wins", "UBER *EATS 1234", "-18.00", 0, "Dining"},
+		{"wildcard parts in order", "eats uber", "-18.00", 0, "Transport"},
+		{"substring ignores case", "Uber Trip", "-9.00", 0, "Transport"},
+		{"other account", "Annual fee", "-50.00", 1, ""},
+		{"same account", "Annual fee", "-50.00", 2, "Fees"},
+		{"above minimum", "AMAZON MKTPLACE", "-120.00", 0, "Electronics"},
+		{"minimum is inclusive", "AMAZON MKTPLACE", "-100.00", 0, "Electronics"},
+		{"between the bounds", "AMAZON MKTPLACE", "-50.00", 0, ""},
+		{"maximum is inclusive", "AMAZON MKTPLACE", "-20.00", 0, "Books"},
+		{"bounds use the absolute amount", "Amazon refund", "20.00", 0, "Books"},
+		{"regex anchors", "ACME  Payroll", "2500.00", 0, "Income"},
+		{"regex is not a substring", "ACME payroll bonus", "500.00", 0, ""},
+		{"no match", "Corner Cafe", "-4.50", 0, ""},
+	}
+	for _, tt := range tests {
+		transaction := tx("2024-03-01", tt.description, tt.amount)
+		transaction.AccountID = tt.accountID
+		r, ok := e.Match(transaction)
+		if got := r.CategoryName; got != tt.want || ok != (tt.want != "") {
+			t.Errorf("%s: Match(%q) = %q, %v, want %q", tt.name, tt.description, got, ok, tt.want)
+		}
+	}
+
+	matches := e.MatchAll([]store.Transaction{
+		tx("2024-03-01", "Corner Cafe", "-4.50"),
+		tx("2024-03-02", "UBER TRIP", "-9.00"),
+	})
+	if len(matches) != 1 || matches[1].Name != "uber" {
+		t.Errorf("MatchAll = %+v, want the uber rule for transaction 1", matches)
+	}
+}
+
+func TestRuleEngineEmptyPattern(t *testing.T) {
+	e, err := NewRuleEngine([]store.Rule{{Name: "stars", Pattern: "**", CategoryName: "Dining"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := e.Match(tx("2024-03-01", "Corner Cafe", "-4.50")); ok {
+		t.Error("a pattern of only wildcards matched")
+	}
+}
+
+func TestRuleEngineInvalidRegex(t *testing.T) {
+	_, err := NewRuleEngine([]store.Rule{{Name: "broken", Pattern: "cafe(", IsRegex: true}})
+	if err == nil || !strings.Contains(err.Error(), `rule "broken"`) {
+		t.Errorf("err = %v, want the rule named", err)
+	}
+}
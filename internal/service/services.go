@@ -2,21 +2,102 @@ package service
 
 import (
 	"context"
+	"path/filepath"
+	"strings"
 
+	"github.com/dylanewe/moni/internal/config"
 	"github.com/dylanewe/moni/internal/store"
 	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
 )
 
+// StatementExtensions lists the statement file types that have a parser.
+var StatementExtensions = []string{".pdf", ".csv", ".ofx", ".qfx"}
+
 type StatementParser interface {
 	ParseStatement(ctx context.Context, categories []string, filepath string) ([]store.Transaction, error)
+	// Name identifies the parser in the import history.
+	Name() string
 }
 
 type Service struct {
+	// Categories maps parser output onto the canonical category list and
+	// is shared by every parser that reads categories.
+	Categories *CategoryResolver
+	Duplicates DuplicateFinder
+	Classifier *LocalCategorizer
+	// Extractor reads the text of PDF statements.
+	Extractor TextExtractor
 	LLMParser StatementParser
+	CSVParser StatementParser
+	OFXParser StatementParser
 }
 
-func NewService(client *openai.Client) Service {
+func NewService(cfg *config.Config) (Service, error) {
+	classifier, err := NewLocalCategorizer(cfg.Classifier)
+	if err != nil {
+		return Service{}, err
+	}
+
+	opts := []option.RequestOption{option.WithAPIKey(cfg.LLM.APIKey)}
+	if cfg.LLM.BaseURL != "" {
+		opts = append(opts, option.WithBaseURL(cfg.LLM.BaseURL))
+	}
+	if cfg.LLM.Timeout > 0 {
+		opts = append(opts, option.WithRequestTimeout(cfg.LLM.Timeout))
+	}
+	client := openai.NewClient(opts...)
+
+	model := cfg.LLM.Model
+	if model == "" {
+		model = openai.ChatModelGPT4oMini
+	}
+
+	chunkSize := cfg.LLM.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	concurrency := cfg.LLM.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultConcurrency
+	}
+	repairAttempts := defaultRepairAttempts
+	if cfg.LLM.RepairAttempts != nil {
+		repairAttempts = max(*cfg.LLM.RepairAttempts, 0)
+	}
+
+	resolver := NewCategoryResolver(cfg.CategorySynonyms, cfg.CategoryMatchThreshold)
+	extractor := NewTextExtractor(cfg.PDF.Extractor)
+
 	return Service{
-		LLMParser: &LLMParserService{client},
+		Categories: resolver,
+		Duplicates: NewDuplicateFinder(cfg.Dedupe),
+		Classifier: classifier,
+		Extractor:  extractor,
+		LLMParser: &LLMParserService{
+			client:         &client,
+			extractor:      extractor,
+			model:          model,
+			maxTokens:      cfg.LLM.MaxTokens,
+			local:          cfg.LLM.BaseURL != "",
+			chunkSize:      chunkSize,
+			concurrency:    concurrency,
+			repairAttempts: repairAttempts,
+			resolver:       resolver,
+		},
+		CSVParser: &CSVParserService{cfg.Banks},
+		OFXParser: &OFXParserService{},
+	}, nil
+}
+
+// ParserFor returns the parser that handles the given statement file based on its extension.
+func (s *Service) ParserFor(file string) StatementParser {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".csv":
+		return s.CSVParser
+	case ".ofx", ".qfx":
+		return s.OFXParser
+	default:
+		return s.LLMParser
 	}
 }
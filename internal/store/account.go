@@ -0,0 +1,81 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// AccountTypes lists the kinds of account moni knows about.
+var AccountTypes = []string{"checking", "savings", "credit", "cash", "investment"}
+
+// Account is a bank account or card that statements are imported for.
+// Last4 holds the last four digits of the account or card number, which
+// statements usually print and which are used to detect the account.
+type Account struct {
+	ID          int64
+	Name        string
+	Type        string
+	Institution string
+	Currency    string
+	Last4       string
+	// Transactions is only set by GetAll.
+	Transactions int64
+}
+
+type AccountStore struct {
+	db *sql.DB
+}
+
+func (s *AccountStore) Insert(ctx context.Context, acc *Account) error {
+	if acc.Type == "" {
+		acc.Type = AccountTypes[0]
+	}
+
+	query := `
+		INSERT INTO accounts (name, type, institution, currency, last4)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	return s.db.QueryRowContext(ctx, query, acc.Name, acc.Type, nullString(acc.Institution),
+		nullString(acc.Currency), nullString(acc.Last4)).Scan(&acc.ID)
+}
+
+// GetAll returns every account with its number of transactions, sorted by
+// name.
+func (s *AccountStore) GetAll(ctx context.Context) ([]Account, error) {
+	query := `
+		SELECT a.id, a.name, a.type, COALESCE(a.institution, ''), COALESCE(a.currency, ''),
+			COALESCE(a.last4, ''), COUNT(t.id)
+		FROM accounts a
+		LEFT JOIN transactions t ON t.account_id = a.id
+		GROUP BY a.id, a.name, a.type, a.institution, a.currency, a.last4
+		ORDER BY a.name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []Account
+	for rows.Next() {
+		var a Account
+		if err := rows.Scan(&a.ID, &a.Name, &a.Type, &a.Institution, &a.Currency, &a.Last4, &a.Transactions); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+
+	return accounts, rows.Err()
+}
+
+// Delete removes an account. Its transactions are kept without an account.
+func (s *AccountStore) Delete(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM accounts WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
@@ -3,34 +3,69 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 )
 
+// PathSeparator joins the names of nested categories, as in "Food > Dining".
+const PathSeparator = " > "
+
+var ErrCategoryCycle = errors.New("a category cannot be moved under itself or its subcategories")
+
 type Category struct {
 	ID   int64
 	Name string
+	// ParentID is 0 for top-level categories. Names are unique among
+	// siblings only.
+	ParentID int64
+	// Path, Ref, Depth and Transactions, the number of transactions filed
+	// directly under the category or split into it, are only set by GetAll.
+	// Ref is what a CategoryName holds for the category: its name, or its
+	// path when other categories share the name.
+	Path         string
+	Ref          string
+	Depth        int
+	Transactions int64
 }
 
 type CategoryStore struct {
 	db *sql.DB
 }
 
+// categoryMap maps the refs and paths of the categories to their IDs.
+// Names shared by several categories map to 0.
 type categoryMap map[string]int64
 
-func (s *CategoryStore) Insert(ctx context.Context, cat *Category) error {
-	query := `INSERT INTO categories (id, name) VALUES ($1, $2)`
-
-_, err := s.db.ExecContext(ctx, query, cat.ID, cat.Name)
-	if err != nil {
-		return err
+// lookup returns the ID of the category a CategoryName refers to.
+func (m categoryMap) lookup(name string) (int64, error) {
+	id, exists := m[name]
+	if !exists {
+		return 0, fmt.Errorf("category not found: %s", name)
+	}
+	if id == 0 {
+		return 0, fmt.Errorf("category %q is ambiguous, use its path", name)
 	}
+	return id, nil
+}
+
+func (s *CategoryStore) Insert(ctx context.Context, cat *Category) error {
+	query := `INSERT INTO categories (name, parent_id) VALUES ($1, $2) RETURNING id`
 
-	return nil
+	return s.db.QueryRowContext(ctx, query, cat.Name, nullInt64(cat.ParentID)).Scan(&cat.ID)
 }
 
+// GetAll returns the category tree depth first, with siblings sorted by name.
 func (s *CategoryStore) GetAll(ctx context.Context) ([]Category, error) {
-	query := `SELECT id, name FROM categories`
+	query := `
+		SELECT c.id, c.name, COALESCE(c.parent_id, 0), COUNT(DISTINCT a.transaction_id)
+		FROM categories c
+		LEFT JOIN allocations a ON a.category_id = c.id
+		GROUP BY c.id, c.name, c.parent_id
+	`
 
-rows, err := s.db.QueryContext(ctx, query)
+	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -39,34 +74,237 @@ rows, err := s.db.QueryContext(ctx, query)
 	var categories []Category
 	for rows.Next() {
 		var cat Category
-		if err := rows.Scan(&cat.ID, &cat.Name); err != nil {
+		if err := rows.Scan(&cat.ID, &cat.Name, &cat.ParentID, &cat.Transactions); err != nil {
 			return nil, err
 		}
 
 		categories = append(categories, cat)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sortTree(categories), nil
+}
+
+func (s *CategoryStore) Rename(ctx context.Context, id int64, name string) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE categories SET name = $2 WHERE id = $1`, id, name)
+	if err != nil {
+		return err
+	}
+
+	return expectRow(res)
+}
+
+// SetParent moves a category under parentID, or to the top level when
+// parentID is 0.
+func (s *CategoryStore) SetParent(ctx context.Context, id, parentID int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if parentID != 0 {
+			cycle, err := isWithin(ctx, tx, parentID, id)
+			if err != nil {
+				return err
+			}
+			if cycle {
+				return ErrCategoryCycle
+			}
+		}
+
+		res, err := tx.ExecContext(ctx, `UPDATE categories SET parent_id = $2 WHERE id = $1`, id, nullInt64(parentID))
+		if err != nil {
+			return err
+		}
+		return expectRow(res)
+	})
+}
+
+// Merge moves the transactions, rules, learned categories and subcategories
+// of from into into and deletes from.
+func (s *CategoryStore) Merge(ctx context.Context, from, into int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		cycle, err := isWithin(ctx, tx, into, from)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrCategoryCycle
+		}
+
+		for _, query := range []string{
+			`UPDATE categories SET parent_id = $2 WHERE parent_id = $1`,
+			`UPDATE transactions SET category_id = $2 WHERE category_id = $1`,
+			`UPDATE transaction_splits SET category_id = $2 WHERE category_id = $1`,
+			`UPDATE category_rules SET category_id = $2 WHERE category_id = $1`,
+			`UPDATE merchant_memory SET category_id = $2 WHERE category_id = $1`,
+		} {
+			if _, err := tx.ExecContext(ctx, query, from, into); err != nil {
+				return err
+			}
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, from)
+		if err != nil {
+			return err
+		}
+		return expectRow(res)
+	})
+}
+
+// Delete removes a category together with its rules and learned categories.
+// Its transactions move to moveTo, or become uncategorized when moveTo is 0,
+// and its subcategories move up a level.
+func (s *CategoryStore) Delete(ctx context.Context, id, moveTo int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE categories SET parent_id = (SELECT parent_id FROM categories WHERE id = $1) WHERE parent_id = $1`
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+
+		if moveTo != 0 {
+			for _, query := range []string{
+				`UPDATE transactions SET category_id = $2 WHERE category_id = $1`,
+				`UPDATE transaction_splits SET category_id = $2 WHERE category_id = $1`,
+			} {
+				if _, err := tx.ExecContext(ctx, query, id, moveTo); err != nil {
+					return err
+				}
+			}
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		return expectRow(res)
+	})
+}
+
+// isWithin reports whether id is ancestor itself or one of its
+// subcategories.
+func isWithin(ctx context.Context, tx *sql.Tx, id, ancestor int64) (bool, error) {
+	query := `
+		WITH RECURSIVE up AS (
+			SELECT id, parent_id FROM categories WHERE id = $1
+			UNION
+			SELECT c.id, c.parent_id FROM categories c JOIN up ON c.id = up.parent_id
+		)
+		SELECT COUNT(*) FROM up WHERE id = $2
+	`
+
+	var n int
+	if err := tx.QueryRowContext(ctx, query, id, ancestor).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// sortTree orders categories depth first and fills in their paths.
+// Categories whose parent is missing are shown at the top level.
+func sortTree(categories []Category) []Category {
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+
+	ids := make(map[int64]bool, len(categories))
+	for _, c := range categories {
+		ids[c.ID] = true
+	}
+	children := make(map[int64][]Category)
+	for _, c := range categories {
+		parent := c.ParentID
+		if !ids[parent] {
+			parent = 0
+		}
+		children[parent] = append(children[parent], c)
+	}
 
-	return categories, rows.Err()
+	sorted := make([]Category, 0, len(categories))
+	seen := make(map[int64]bool, len(categories))
+	var walk func(parent int64, path string, depth int)
+	walk = func(parent int64, path string, depth int) {
+		for _, c := range children[parent] {
+			if seen[c.ID] {
+				continue
+			}
+			seen[c.ID] = true
+			c.Path = c.Name
+			if path != "" {
+				c.Path = path + PathSeparator + c.Name
+			}
+			c.Depth = depth
+			sorted = append(sorted, c)
+			walk(c.ID, c.Path, depth+1)
+		}
+	}
+	walk(0, "", 0)
+	// Categories caught in a parent cycle are never reached from the top.
+	for _, c := range categories {
+		if !seen[c.ID] {
+			c.ParentID = 0
+			seen[c.ID] = true
+			c.Path = c.Name
+			sorted = append(sorted, c)
+			walk(c.ID, c.Path, 1)
+		}
+	}
+
+	names := make(map[string]int, len(sorted))
+	for _, c := range sorted {
+		names[c.Name]++
+	}
+	for i := range sorted {
+		sorted[i].Ref = sorted[i].Name
+		if names[sorted[i].Name] > 1 {
+			sorted[i].Ref = sorted[i].Path
+		}
+	}
+
+	return sorted
+}
+
+// SplitPath splits a category path such as "Food > Dining" into its names.
+func SplitPath(path string) []string {
+	var names []string
+	for _, name := range strings.Split(path, strings.TrimSpace(PathSeparator)) {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
 func getCategoryMap(ctx context.Context, tx *sql.Tx) (categoryMap, error) {
-	query := `SELECT id, name FROM categories`
+	query := `SELECT id, name, path, ref FROM category_paths`
 
-rows, err := tx.QueryContext(ctx, query)
+	rows, err := tx.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	categoryMap := make(categoryMap)
+	var categories []Category
 	for rows.Next() {
-		var id int64
-		var name string
-		if err := rows.Scan(&id, &name); err != nil {
+		var c Category
+		if err := rows.Scan(&c.ID, &c.Name, &c.Path, &c.Ref); err != nil {
 			return nil, err
 		}
-		categoryMap[name] = id
+		categories = append(categories, c)
 	}
 
-	return categoryMap, rows.Err()
+	return newCategoryMap(categories), rows.Err()
+}
+
+// newCategoryMap maps categories with their paths and refs filled in.
+func newCategoryMap(categories []Category) categoryMap {
+	categoryMap := make(categoryMap)
+	for _, c := range categories {
+		categoryMap[c.Path] = c.ID
+		categoryMap[c.Ref] = c.ID
+	}
+	for _, c := range categories {
+		if _, exists := categoryMap[c.Name]; !exists {
+			categoryMap[c.Name] = 0
+		}
+	}
+	return categoryMap
 }
@@ -4,6 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
+
+	"github.com/dylanewe/moni/internal/money"
 )
 
 // DashboardStore provides methods for accessing dashboard data.
@@ -11,51 +14,115 @@ type DashboardStore struct {
 	db *sql.DB
 }
 
-// GetTotalIncomeAndExpense returns the total income and expense from all transactions.
-func (s *DashboardStore) GetTotalIncomeAndExpense() (float64, float64, error) {
+// GetTotalIncomeAndExpense returns the total income and expense from all transactions,
+// or only from those of accountID when it is non-zero, per currency.
+func (s *DashboardStore) GetTotalIncomeAndExpense(accountID int64) (money.Totals, money.Totals, error) {
+	income, expense, err := s.incomeAndExpense(`account_id = $1 OR $1 = 0`, accountID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query total income and expense: %w", err)
+	}
+	return income, expense, nil
+}
+
+// GetMonthlyIncomeAndExpense returns the income and expense for a specific month and year,
+// per currency and optionally limited to one account.
+func (s *DashboardStore) GetMonthlyIncomeAndExpense(year int, month int, accountID int64) (money.Totals, money.Totals, error) {
+	start, end := monthRange(year, month)
+	income, expense, err := s.incomeAndExpense(`date >= $1 AND date < $2 AND (account_id = $3 OR $3 = 0)`, start, end, accountID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query monthly income and expense: %w", err)
+	}
+	return income, expense, nil
+}
+
+// incomeAndExpense sums the transactions matching where per currency.
+func (s *DashboardStore) incomeAndExpense(where string, args ...any) (money.Totals, money.Totals, error) {
 	query := `
 		SELECT
-			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0) AS total_income,
-			COALESCE(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END), 0) AS total_expense
-		FROM transactions;
+			COALESCE(currency, ''),
+			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0) AS income,
+			COALESCE(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END), 0) AS expense
+		FROM transactions
+		WHERE ` + where + `
+		GROUP BY COALESCE(currency, '')
 	`
-	rows, err := s.db.QueryContext(context.Background(), query)
+	rows, err := s.db.QueryContext(context.Background(), query, args...)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to query total income and expense: %w", err)
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	var totalIncome, totalExpense float64
-	if rows.Next() {
-		if err := rows.Scan(&totalIncome, &totalExpense); err != nil {
-			return 0, 0, fmt.Errorf("failed to scan total income and expense: %w", err)
+	var income, expense money.Totals
+	for rows.Next() {
+		var currency string
+		var in, out money.Money
+		if err := rows.Scan(&currency, &in, &out); err != nil {
+			return nil, nil, err
 		}
+		income = income.Add(in.In(currency))
+		expense = expense.Add(out.In(currency))
 	}
 
-	return totalIncome, totalExpense, nil
+	return income, expense, rows.Err()
+}
+
+// monthRange returns the first day of a month and of the month after it, so
+// queries can select the month without date functions that differ between
+// databases.
+func monthRange(year, month int) (string, string) {
+	start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	return start.Format(dateLayout), start.AddDate(0, 1, 0).Format(dateLayout)
+}
+
+// CategoryTotal is the sum of a category's transactions and splits including
+// those of its subcategories, per currency.
+type CategoryTotal struct {
+	ID       int64
+	Name     string
+	ParentID int64
+	Total    money.Totals
 }
 
-// GetMonthlyIncomeAndExpense returns the income and expense for a specific month and year.
-func (s *DashboardStore) GetMonthlyIncomeAndExpense(year int, month int) (float64, float64, error) {
+// GetMonthlyCategoryTotals returns the total per category for a specific
+// month, rolled up the category tree and optionally limited to one account.
+func (s *DashboardStore) GetMonthlyCategoryTotals(year int, month int, accountID int64) ([]CategoryTotal, error) {
 	query := `
-		SELECT
-			COALESCE(SUM(CASE WHEN amount > 0 THEN amount ELSE 0 END), 0) AS monthly_income,
-			COALESCE(SUM(CASE WHEN amount < 0 THEN amount ELSE 0 END), 0) AS monthly_expense
-		FROM transactions
-		WHERE EXTRACT(YEAR FROM date) = $1 AND EXTRACT(MONTH FROM date) = $2;
+		WITH RECURSIVE tree AS (
+			SELECT id AS root_id, id FROM categories
+			UNION
+			SELECT tree.root_id, c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+		)
+		SELECT c.id, c.name, COALESCE(c.parent_id, 0), COALESCE(a.currency, ''), COALESCE(SUM(a.amount), 0)
+		FROM categories c
+		JOIN tree ON tree.root_id = c.id
+		LEFT JOIN allocations a ON a.category_id = tree.id
+			AND a.date >= $1 AND a.date < $2
+			AND (a.account_id = $3 OR $3 = 0)
+		GROUP BY c.id, c.name, c.parent_id, COALESCE(a.currency, '')
+		ORDER BY c.name, c.id;
 	`
-	rows, err := s.db.QueryContext(context.Background(), query, year, month)
+	start, end := monthRange(year, month)
+	rows, err := s.db.QueryContext(context.Background(), query, start, end, accountID)
 	if err != nil {
-		return 0, 0, fmt.Errorf("failed to query monthly income and expense: %w", err)
+		return nil, fmt.Errorf("failed to query category totals: %w", err)
 	}
 	defer rows.Close()
 
-	var monthlyIncome, monthlyExpense float64
-	if rows.Next() {
-		if err := rows.Scan(&monthlyIncome, &monthlyExpense); err != nil {
-			return 0, 0, fmt.Errorf("failed to scan monthly income and expense: %w", err)
+	// Each category comes back once per currency.
+	var totals []CategoryTotal
+	for rows.Next() {
+		var t CategoryTotal
+		var currency string
+		var total money.Money
+		if err := rows.Scan(&t.ID, &t.Name, &t.ParentID, &currency, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan category totals: %w", err)
+		}
+		if n := len(totals); n == 0 || totals[n-1].ID != t.ID {
+			totals = append(totals, t)
 		}
+		last := &totals[len(totals)-1]
+		last.Total = last.Total.Add(total.In(currency))
 	}
 
-	return monthlyIncome, monthlyExpense, nil
+	return totals, rows.Err()
 }
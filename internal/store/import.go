@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Import records where a batch of transactions came from, so the whole batch
+// can be listed and rolled back later.
+type Import struct {
+	ID          int64
+	FileName    string
+	ContentHash string
+	Parser      string
+	Model       string
+	// AccountID is the account the statement belongs to, 0 when unknown.
+	AccountID  int64
+	RowCount   int64
+	ImportedAt time.Time
+}
+
+type ImportStore struct {
+	db *sql.DB
+}
+
+// Create records the import and inserts its transactions in one database
+// transaction. RowCount is set to the number of rows actually inserted.
+func (s *ImportStore) Create(ctx context.Context, imp *Import, transactions []Transaction) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			INSERT INTO imports (file_name, content_hash, parser, model, account_id)
+			VALUES ($1, $2, $3, $4, $5)
+			RETURNING id, imported_at
+		`
+		err := tx.QueryRowContext(ctx, query, imp.FileName, imp.ContentHash, imp.Parser, nullString(imp.Model),
+			nullInt64(imp.AccountID)).Scan(&imp.ID, &imp.ImportedAt)
+		if err != nil {
+			return err
+		}
+
+		rows, err := insertTransactions(ctx, tx, imp.ID, imp.AccountID, transactions)
+		if err != nil {
+			return err
+		}
+		imp.RowCount = rows
+
+		_, err = tx.ExecContext(ctx, `UPDATE imports SET row_count = $1 WHERE id = $2`, rows, imp.ID)
+		return err
+	})
+}
+
+func (s *ImportStore) GetAll(ctx context.Context) ([]Import, error) {
+	query := `
+		SELECT id, file_name, content_hash, parser, COALESCE(model, ''), COALESCE(account_id, 0), row_count, imported_at
+		FROM imports
+		ORDER BY imported_at DESC, id DESC
+	`
+
+	return queryImports(ctx, s.db, query)
+}
+
+// FindByHash returns earlier imports of a file with the given content hash,
+// newest first.
+func (s *ImportStore) FindByHash(ctx context.Context, hash string) ([]Import, error) {
+	query := `
+		SELECT id, file_name, content_hash, parser, COALESCE(model, ''), COALESCE(account_id, 0), row_count, imported_at
+		FROM imports
+		WHERE content_hash = $1
+		ORDER BY imported_at DESC, id DESC
+	`
+
+	return queryImports(ctx, s.db, query, hash)
+}
+
+func queryImports(ctx context.Context, db *sql.DB, query string, args ...any) ([]Import, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var imports []Import
+	for rows.Next() {
+		var imp Import
+		if err := rows.Scan(&imp.ID, &imp.FileName, &imp.ContentHash, &imp.Parser, &imp.Model, &imp.AccountID, &imp.RowCount, &imp.ImportedAt); err != nil {
+			return nil, err
+		}
+
+		imports = append(imports, imp)
+	}
+
+	return imports, rows.Err()
+}
+
+// Delete rolls back an import by removing it together with its transactions.
+func (s *ImportStore) Delete(ctx context.Context, id int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM transactions WHERE import_id = $1`, id); err != nil {
+			return err
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM imports WHERE id = $1`, id)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return sql.ErrNoRows
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,1196 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dylanewe/moni/internal/money"
+)
+
+// NewMemoryStore returns a Store that keeps everything in memory. It behaves
+// like a freshly migrated database, which has no categories until db.Init
+// adds the configured ones, and is safe for concurrent use.
+func NewMemoryStore() Store {
+	m := &memory{lastID: make(map[string]int64), tagged: make(map[memoryTag]bool)}
+
+	return Store{
+		Transactions: &memoryTransactions{m},
+		Categories:   &memoryCategories{m},
+		Dashboard:    &memoryDashboard{m},
+		Accounts:     &memoryAccounts{m},
+		Imports:      &memoryImports{m},
+		Rules:        &memoryRules{m},
+		Merchants:    &memoryMerchants{m},
+		Tags:         &memoryTags{m},
+	}
+}
+
+// memory holds the tables of the in-memory store. References between them
+// are kept by ID, like foreign keys, so renames show up everywhere.
+type memory struct {
+	mu           sync.Mutex
+	lastID       map[string]int64
+	categories   []Category
+	accounts     []Account
+	imports      []Import
+	transactions []memoryTransaction
+	rules        []memoryRule
+	merchants    []memoryMerchant
+	tags         []Tag
+	tagged       map[memoryTag]bool
+}
+
+type memoryTransaction struct {
+	Transaction
+	categoryID int64
+	importID   int64
+	splits     []memorySplit
+}
+
+// Amounts are kept in cents, like the database columns, without a
+// currency of their own.
+type memorySplit struct {
+	categoryID int64
+	cents      int64
+}
+
+type memoryRule struct {
+	Rule
+	categoryID int64
+}
+
+type memoryMerchant struct {
+	Merchant
+	categoryID int64
+}
+
+type memoryTag struct {
+	transactionID int64
+	tagID         int64
+}
+
+// allocation is a row of the allocations view: a split, or the whole
+// transaction when it is not split.
+type allocation struct {
+	categoryID int64
+	cents      int64
+	tx         *memoryTransaction
+}
+
+func (m *memory) nextID(table string) int64 {
+	m.lastID[table]++
+	return m.lastID[table]
+}
+
+// tree returns the categories with their paths and refs, like the
+// category_paths view.
+func (m *memory) tree() []Category {
+	return sortTree(slices.Clone(m.categories))
+}
+
+// categoryID looks a CategoryName up like the SQL categoryMap.
+func (m *memory) categoryID(name string) (int64, error) {
+	return newCategoryMap(m.tree()).lookup(name)
+}
+
+// categoryName returns the CategoryName of a category: its ref.
+func (m *memory) categoryName(id int64) string {
+	for _, c := range m.tree() {
+		if c.ID == id {
+			return c.Ref
+		}
+	}
+	return ""
+}
+
+// hasSibling reports whether a category other than id is named name under
+// parentID, which the unique index on categories forbids.
+func (m *memory) hasSibling(parentID int64, name string, id int64) bool {
+	return slices.ContainsFunc(m.categories, func(c Category) bool {
+		return c.ParentID == parentID && c.Name == name && c.ID != id
+	})
+}
+
+func (m *memory) category(id int64) *Category {
+	for i := range m.categories {
+		if m.categories[i].ID == id {
+			return &m.categories[i]
+		}
+	}
+	return nil
+}
+
+func (m *memory) transaction(id int64) *memoryTransaction {
+	for i := range m.transactions {
+		if m.transactions[i].ID == id {
+			return &m.transactions[i]
+		}
+	}
+	return nil
+}
+
+func (m *memory) allocations() []allocation {
+	var allocations []allocation
+	for i := range m.transactions {
+		t := &m.transactions[i]
+		if len(t.splits) == 0 {
+			allocations = append(allocations, allocation{t.categoryID, t.Amount.Cents, t})
+			continue
+		}
+		for _, s := range t.splits {
+			allocations = append(allocations, allocation{s.categoryID, s.cents, t})
+		}
+	}
+	return allocations
+}
+
+// allocatedTo reports whether any part of t is allocated to one of the
+// categories, like a lookup in the allocations view.
+func (t *memoryTransaction) allocatedTo(categories map[int64]bool) bool {
+	if len(t.splits) == 0 {
+		return categories[t.categoryID]
+	}
+	for _, s := range t.splits {
+		if categories[s.categoryID] {
+			return true
+		}
+	}
+	return false
+}
+
+// subtree returns the IDs of a category and all of its subcategories.
+func (m *memory) subtree(id int64) map[int64]bool {
+	ids := map[int64]bool{id: true}
+	for changed := true; changed; {
+		changed = false
+		for _, c := range m.categories {
+			if ids[c.ParentID] && !ids[c.ID] {
+				ids[c.ID] = true
+				changed = true
+			}
+		}
+	}
+	return ids
+}
+
+// toTransaction returns a copy of t as the SQL store would read it.
+func (m *memory) toTransaction(t *memoryTransaction) Transaction {
+	tx := t.Transaction
+	tx.CategoryName = m.categoryName(t.categoryID)
+	tx.Tags = nil
+	tx.Splits = nil
+	return tx
+}
+
+// byDate returns the transactions dated between startDate and endDate
+// inclusive, oldest first.
+func (m *memory) byDate(startDate, endDate string, keep func(*memoryTransaction) bool) []Transaction {
+	var transactions []Transaction
+	for i := range m.transactions {
+		t := &m.transactions[i]
+		if t.Date >= startDate && t.Date <= endDate && keep(t) {
+			transactions = append(transactions, m.toTransaction(t))
+		}
+	}
+	sortTransactions(transactions)
+	return transactions
+}
+
+func sortTransactions(transactions []Transaction) {
+	sort.Slice(transactions, func(i, j int) bool {
+		if transactions[i].Date != transactions[j].Date {
+			return transactions[i].Date < transactions[j].Date
+		}
+		return transactions[i].ID < transactions[j].ID
+	})
+}
+
+// insertTransactions mirrors the SQL insertTransactions: everything is
+// checked first, so a failed insert leaves the store untouched like a
+// rolled back transaction.
+func (m *memory) insertTransactions(importID, accountID int64, transactions []Transaction) (int64, error) {
+	for _, t := range transactions {
+		if _, err := m.categoryID(t.CategoryName); err != nil {
+			return 0, err
+		}
+		if err := ValidateSplits(t.Amount, t.Splits); err != nil {
+			return 0, fmt.Errorf("transaction %q: %w", t.Description, err)
+		}
+		for _, s := range t.Splits {
+			if _, err := m.categoryID(s.CategoryName); err != nil {
+				return 0, err
+			}
+		}
+		for _, tag := range t.Tags {
+			if NormalizeTag(tag) == "" {
+				return 0, fmt.Errorf("empty tag")
+			}
+		}
+	}
+
+	var inserted int64
+	for i := range transactions {
+		t := &transactions[i]
+		if t.AccountID == 0 {
+			t.AccountID = accountID
+		}
+		if t.FITID != "" && m.hasFITID(t.AccountID, t.FITID) {
+			continue
+		}
+
+		t.ID = m.nextID("transactions")
+		stored := memoryTransaction{Transaction: *t, importID: importID}
+		stored.categoryID, _ = m.categoryID(t.CategoryName)
+		for _, s := range t.Splits {
+			id, _ := m.categoryID(s.CategoryName)
+			stored.splits = append(stored.splits, memorySplit{id, s.Amount.Cents})
+		}
+		m.transactions = append(m.transactions, stored)
+		inserted++
+
+		for _, tag := range t.Tags {
+			m.tag(tag, []int64{t.ID})
+		}
+	}
+
+	return inserted, nil
+}
+
+func (m *memory) hasFITID(accountID int64, fitid string) bool {
+	for _, t := range m.transactions {
+		if t.AccountID == accountID && t.FITID == fitid {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *memory) deleteTransactions(keep func(*memoryTransaction) bool) {
+	kept := m.transactions[:0]
+	for _, t := range m.transactions {
+		if keep(&t) {
+			kept = append(kept, t)
+			continue
+		}
+		for link := range m.tagged {
+			if link.transactionID == t.ID {
+				delete(m.tagged, link)
+			}
+		}
+	}
+	m.transactions = kept
+}
+
+// tag links a tag, created when needed, to transactions that are known to
+// exist.
+func (m *memory) tag(name string, ids []int64) {
+	name = NormalizeTag(name)
+	i := slices.IndexFunc(m.tags, func(t Tag) bool { return t.Name == name })
+	if i < 0 {
+		m.tags = append(m.tags, Tag{ID: m.nextID("tags"), Name: name})
+		i = len(m.tags) - 1
+	}
+	for _, id := range ids {
+		m.tagged[memoryTag{id, m.tags[i].ID}] = true
+	}
+}
+
+type memoryTransactions struct {
+	*memory
+}
+
+func (s *memoryTransactions) Insert(ctx context.Context, transactions []Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.insertTransactions(0, 0, transactions)
+	return err
+}
+
+func (s *memoryTransactions) GetAll(ctx context.Context) ([]Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var transactions []Transaction
+	for i := range s.transactions {
+		if s.category(s.transactions[i].categoryID) != nil {
+			transactions = append(transactions, s.toTransaction(&s.transactions[i]))
+		}
+	}
+	sortTransactions(transactions)
+	return transactions, nil
+}
+
+func (s *memoryTransactions) Split(ctx context.Context, id int64, splits []Split) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.transaction(id)
+	if t == nil {
+		return sql.ErrNoRows
+	}
+	if err := ValidateSplits(t.Amount, splits); err != nil {
+		return err
+	}
+
+	var stored []memorySplit
+	for _, split := range splits {
+		categoryID, err := s.categoryID(split.CategoryName)
+		if err != nil {
+			return err
+		}
+		stored = append(stored, memorySplit{categoryID, split.Amount.Cents})
+	}
+	t.splits = stored
+	return nil
+}
+
+func (s *memoryTransactions) GetSplits(ctx context.Context, id int64) ([]Split, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.transaction(id)
+	if t == nil {
+		return nil, nil
+	}
+	var splits []Split
+	for _, split := range t.splits {
+		splits = append(splits, Split{CategoryName: s.categoryName(split.categoryID), Amount: money.New(split.cents, t.Amount.Currency)})
+	}
+	return splits, nil
+}
+
+func (s *memoryTransactions) Get(ctx context.Context, id int64) (Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.transaction(id)
+	if t == nil {
+		return Transaction{}, sql.ErrNoRows
+	}
+	tx := s.toTransaction(t)
+	for _, split := range t.splits {
+		tx.Splits = append(tx.Splits, Split{CategoryName: s.categoryName(split.categoryID), Amount: money.New(split.cents, t.Amount.Currency)})
+	}
+	for _, tag := range s.tags {
+		if s.tagged[memoryTag{id, tag.ID}] {
+			tx.Tags = append(tx.Tags, tag.Name)
+		}
+	}
+	slices.Sort(tx.Tags)
+	return tx, nil
+}
+
+func (s *memoryTransactions) Update(ctx context.Context, tx *Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var categoryID int64
+	if tx.CategoryName != "" {
+		id, err := s.categoryID(tx.CategoryName)
+		if err != nil {
+			return err
+		}
+		categoryID = id
+	}
+	t := s.transaction(tx.ID)
+	if t == nil {
+		return sql.ErrNoRows
+	}
+	var allocated int64
+	for _, split := range t.splits {
+		allocated += split.cents
+	}
+	if r := tx.Amount.Cents - allocated; len(t.splits) > 0 && r != 0 {
+		return fmt.Errorf("splits are off by %s", money.New(r, "").Decimal())
+	}
+
+	t.Description = tx.Description
+	t.categoryID = categoryID
+	t.Amount = tx.Amount
+	t.Date = tx.Date
+	t.AccountID = tx.AccountID
+	t.Notes = tx.Notes
+	return nil
+}
+
+func (s *memoryTransactions) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.transaction(id) == nil {
+		return sql.ErrNoRows
+	}
+	s.deleteTransactions(func(t *memoryTransaction) bool { return t.ID != id })
+	return nil
+}
+
+func (s *memoryTransactions) SetMissingCurrency(ctx context.Context, currency string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var n int64
+	for i := range s.transactions {
+		if t := &s.transactions[i]; t.Amount.Currency == "" {
+			t.Amount = t.Amount.In(currency)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryTransactions) List(ctx context.Context, q TransactionQuery) (TransactionPage, error) {
+	after, err := checkQuery(&q)
+	if err != nil {
+		return TransactionPage{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tagID := int64(-1)
+	if q.Tag != "" {
+		name := NormalizeTag(q.Tag)
+		if i := slices.IndexFunc(s.tags, func(t Tag) bool { return t.Name == name }); i >= 0 {
+			tagID = s.tags[i].ID
+		}
+	}
+	var categories map[int64]bool
+	if q.CategoryName != "" {
+		categories = map[int64]bool{}
+		if id, err := s.categoryID(q.CategoryName); err == nil {
+			categories = s.subtree(id)
+		}
+	}
+	description := strings.ToLower(q.Description)
+
+	var transactions []Transaction
+	for i := range s.transactions {
+		t := &s.transactions[i]
+		tx := s.toTransaction(t)
+		switch {
+		case q.StartDate != "" && tx.Date < q.StartDate,
+			q.EndDate != "" && tx.Date > q.EndDate,
+			categories != nil && !t.allocatedTo(categories),
+			q.AccountID != 0 && tx.AccountID != q.AccountID,
+			q.MinAmount != nil && tx.Amount.Cents < q.MinAmount.Cents,
+			q.MaxAmount != nil && tx.Amount.Cents > q.MaxAmount.Cents,
+			!strings.Contains(strings.ToLower(tx.Description), description),
+			q.Tag != "" && !s.tagged[memoryTag{t.ID, tagID}]:
+			continue
+		}
+		if after != nil && compareSort(tx, q.Sort, after.Value, after.ID)*direction(q) <= 0 {
+			continue
+		}
+		transactions = append(transactions, tx)
+	}
+
+	sort.Slice(transactions, func(i, j int) bool {
+		b := transactions[j]
+		return compareSort(transactions[i], q.Sort, sortValue(b, q.Sort), b.ID)*direction(q) < 0
+	})
+	if q.Limit > 0 && len(transactions) > q.Limit+1 {
+		transactions = transactions[:q.Limit+1]
+	}
+	return nextPage(q, transactions), nil
+}
+
+func (s *memoryTransactions) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	if strings.TrimSpace(q.Text) == "" {
+		return nil, fmt.Errorf("empty search")
+	}
+	page, err := s.List(ctx, TransactionQuery{StartDate: q.StartDate, EndDate: q.EndDate, CategoryName: q.CategoryName})
+	if err != nil {
+		return nil, err
+	}
+	return rankTransactions(q, page.Transactions), nil
+}
+
+// compareSort compares t with the transaction whose sort value and ID are
+// given, the way List orders them ascending.
+func compareSort(t Transaction, column, value string, id int64) int {
+	own := sortValue(t, column)
+	c := strings.Compare(own, value)
+	if isNumericSort(column) {
+		a, _ := strconv.ParseInt(own, 10, 64)
+		b, _ := strconv.ParseInt(value, 10, 64)
+		c = cmp.Compare(a, b)
+	}
+	if c == 0 {
+		c = cmp.Compare(t.ID, id)
+	}
+	return c
+}
+
+func direction(q TransactionQuery) int {
+	if q.Descending {
+		return -1
+	}
+	return 1
+}
+
+func (s *memoryTransactions) GetByDate(ctx context.Context, startDate, endDate string) ([]Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.byDate(startDate, endDate, func(*memoryTransaction) bool { return true }), nil
+}
+
+func (s *memoryTransactions) GetIncomeByDate(ctx context.Context, startDate, endDate string) (money.Totals, error) {
+	return s.sumByDate(startDate, endDate, func(cents int64) bool { return cents >= 0 }), nil
+}
+
+func (s *memoryTransactions) GetExpenseByDate(ctx context.Context, startDate, endDate string) (money.Totals, error) {
+	return s.sumByDate(startDate, endDate, func(cents int64) bool { return cents < 0 }), nil
+}
+
+// sumByDate adds up amounts per currency.
+func (s *memoryTransactions) sumByDate(startDate, endDate string, keep func(int64) bool) money.Totals {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var sum money.Totals
+	for _, t := range s.byDate(startDate, endDate, func(t *memoryTransaction) bool { return keep(t.Amount.Cents) }) {
+		sum = sum.Add(t.Amount)
+	}
+	return sum
+}
+
+type memoryCategories struct {
+	*memory
+}
+
+func (s *memoryCategories) Insert(ctx context.Context, cat *Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.hasSibling(cat.ParentID, cat.Name, 0) {
+		return fmt.Errorf("category %q already exists", cat.Name)
+	}
+	if cat.ParentID != 0 && s.category(cat.ParentID) == nil {
+		return fmt.Errorf("parent category %d not found", cat.ParentID)
+	}
+
+	cat.ID = s.nextID("categories")
+	s.categories = append(s.categories, Category{ID: cat.ID, Name: cat.Name, ParentID: cat.ParentID})
+	return nil
+}
+
+func (s *memoryCategories) GetAll(ctx context.Context) ([]Category, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counted := make(map[int64]map[int64]bool)
+	for _, a := range s.allocations() {
+		if counted[a.categoryID] == nil {
+			counted[a.categoryID] = make(map[int64]bool)
+		}
+		counted[a.categoryID][a.tx.ID] = true
+	}
+
+	categories := make([]Category, len(s.categories))
+	for i, c := range s.categories {
+		categories[i] = Category{ID: c.ID, Name: c.Name, ParentID: c.ParentID, Transactions: int64(len(counted[c.ID]))}
+	}
+	return sortTree(categories), nil
+}
+
+func (s *memoryCategories) Rename(ctx context.Context, id int64, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.category(id)
+	if c == nil {
+		return sql.ErrNoRows
+	}
+	if s.hasSibling(c.ParentID, name, id) {
+		return fmt.Errorf("category %q already exists", name)
+	}
+	c.Name = name
+	return nil
+}
+
+func (s *memoryCategories) SetParent(ctx context.Context, id, parentID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.category(id)
+	if c == nil {
+		return sql.ErrNoRows
+	}
+	if parentID != 0 {
+		if s.category(parentID) == nil {
+			return fmt.Errorf("parent category %d not found", parentID)
+		}
+		if s.subtree(id)[parentID] {
+			return ErrCategoryCycle
+		}
+	}
+	if s.hasSibling(parentID, c.Name, id) {
+		return fmt.Errorf("category %q already exists", c.Name)
+	}
+	c.ParentID = parentID
+	return nil
+}
+
+func (s *memoryCategories) Merge(ctx context.Context, from, into int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.category(from) == nil {
+		return sql.ErrNoRows
+	}
+	if s.category(into) == nil {
+		return fmt.Errorf("category %d not found", into)
+	}
+	if s.subtree(from)[into] {
+		return ErrCategoryCycle
+	}
+	if err := s.checkMove(from, into); err != nil {
+		return err
+	}
+
+	s.moveCategory(from, into)
+	for i := range s.rules {
+		if s.rules[i].categoryID == from {
+			s.rules[i].categoryID = into
+		}
+	}
+	for i := range s.merchants {
+		if s.merchants[i].categoryID == from {
+			s.merchants[i].categoryID = into
+		}
+	}
+	s.deleteCategory(from)
+	return nil
+}
+
+func (s *memoryCategories) Delete(ctx context.Context, id, moveTo int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.category(id)
+	if c == nil {
+		return sql.ErrNoRows
+	}
+	if moveTo != 0 && s.category(moveTo) == nil {
+		return fmt.Errorf("category %d not found", moveTo)
+	}
+
+	parent := c.ParentID
+	if err := s.checkMove(id, parent); err != nil {
+		return err
+	}
+	for i := range s.categories {
+		if s.categories[i].ParentID == id {
+			s.categories[i].ParentID = parent
+		}
+	}
+	// Without moveTo the transactions end up uncategorized, like
+	// ON DELETE SET NULL.
+	s.moveCategory(id, moveTo)
+	s.deleteCategory(id)
+	return nil
+}
+
+// checkMove reports the first subcategory of from whose name is taken under
+// parentID.
+func (s *memoryCategories) checkMove(from, parentID int64) error {
+	for _, c := range s.categories {
+		if c.ParentID == from && s.hasSibling(parentID, c.Name, c.ID) {
+			return fmt.Errorf("category %q already exists", c.Name)
+		}
+	}
+	return nil
+}
+
+// moveCategory files the subcategories, transactions and splits of from
+// under into. Subcategories only move when into is a category.
+func (s *memoryCategories) moveCategory(from, into int64) {
+	for i := range s.categories {
+		if s.categories[i].ParentID == from && into != 0 {
+			s.categories[i].ParentID = into
+		}
+	}
+	for i := range s.transactions {
+		t := &s.transactions[i]
+		if t.categoryID == from {
+			t.categoryID = into
+		}
+		for j := range t.splits {
+			if t.splits[j].categoryID == from {
+				t.splits[j].categoryID = into
+			}
+		}
+	}
+}
+
+// deleteCategory removes a category with the rules and learned categories
+// that point at it.
+func (s *memoryCategories) deleteCategory(id int64) {
+	s.categories = slices.DeleteFunc(s.categories, func(c Category) bool { return c.ID == id })
+	s.rules = slices.DeleteFunc(s.rules, func(r memoryRule) bool { return r.categoryID == id })
+	s.merchants = slices.DeleteFunc(s.merchants, func(m memoryMerchant) bool { return m.categoryID == id })
+}
+
+type memoryDashboard struct {
+	*memory
+}
+
+func (s *memoryDashboard) GetTotalIncomeAndExpense(accountID int64) (money.Totals, money.Totals, error) {
+	income, expense := s.incomeAndExpense(allTime, allTime, accountID)
+	return income, expense, nil
+}
+
+func (s *memoryDashboard) GetMonthlyIncomeAndExpense(year int, month int, accountID int64) (money.Totals, money.Totals, error) {
+	start, end := monthRange(year, month)
+	income, expense := s.incomeAndExpense(start, end, accountID)
+	return income, expense, nil
+}
+
+// allTime passed as both ends of a range selects every date.
+const allTime = ""
+
+// incomeAndExpense sums the transactions dated from start up to but
+// excluding end, per currency.
+func (s *memoryDashboard) incomeAndExpense(start, end string, accountID int64) (money.Totals, money.Totals) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var income, expense money.Totals
+	for _, t := range s.transactions {
+		if !inMonth(t.Date, start, end) || (accountID != 0 && t.AccountID != accountID) {
+			continue
+		}
+		if t.Amount.Cents > 0 {
+			income = income.Add(t.Amount)
+		} else {
+			expense = expense.Add(t.Amount)
+		}
+	}
+	return income, expense
+}
+
+func inMonth(date, start, end string) bool {
+	return start == allTime || (date >= start && date < end)
+}
+
+func (s *memoryDashboard) GetMonthlyCategoryTotals(year int, month int, accountID int64) ([]CategoryTotal, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start, end := monthRange(year, month)
+	var allocations []allocation
+	for _, a := range s.allocations() {
+		if inMonth(a.tx.Date, start, end) && (accountID == 0 || a.tx.AccountID == accountID) {
+			allocations = append(allocations, a)
+		}
+	}
+
+	totals := make([]CategoryTotal, len(s.categories))
+	for i, c := range s.categories {
+		subtree := s.subtree(c.ID)
+		totals[i] = CategoryTotal{ID: c.ID, Name: c.Name, ParentID: c.ParentID}
+		for _, a := range allocations {
+			if subtree[a.categoryID] {
+				totals[i].Total = totals[i].Total.Add(money.New(a.cents, a.tx.Amount.Currency))
+			}
+		}
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Name < totals[j].Name })
+	return totals, nil
+}
+
+type memoryAccounts struct {
+	*memory
+}
+
+func (s *memoryAccounts) Insert(ctx context.Context, acc *Account) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if slices.ContainsFunc(s.accounts, func(a Account) bool { return a.Name == acc.Name }) {
+		return fmt.Errorf("account %q already exists", acc.Name)
+	}
+	if acc.Type == "" {
+		acc.Type = AccountTypes[0]
+	}
+
+	acc.ID = s.nextID("accounts")
+	stored := *acc
+	stored.Transactions = 0
+	s.accounts = append(s.accounts, stored)
+	return nil
+}
+
+func (s *memoryAccounts) GetAll(ctx context.Context) ([]Account, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accounts := slices.Clone(s.accounts)
+	for i := range accounts {
+		for _, t := range s.transactions {
+			if t.AccountID == accounts[i].ID {
+				accounts[i].Transactions++
+			}
+		}
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].Name < accounts[j].Name })
+	return accounts, nil
+}
+
+func (s *memoryAccounts) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.accounts)
+	s.accounts = slices.DeleteFunc(s.accounts, func(a Account) bool { return a.ID == id })
+	if len(s.accounts) == n {
+		return sql.ErrNoRows
+	}
+	for i := range s.transactions {
+		if s.transactions[i].AccountID == id {
+			s.transactions[i].AccountID = 0
+		}
+	}
+	for i := range s.imports {
+		if s.imports[i].AccountID == id {
+			s.imports[i].AccountID = 0
+		}
+	}
+	s.rules = slices.DeleteFunc(s.rules, func(r memoryRule) bool { return r.AccountID == id })
+	return nil
+}
+
+type memoryImports struct {
+	*memory
+}
+
+func (s *memoryImports) Create(ctx context.Context, imp *Import, transactions []Transaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Like a database sequence, the ID is used up even when the insert
+	// fails.
+	id := s.nextID("imports")
+	rows, err := s.insertTransactions(id, imp.AccountID, transactions)
+	if err != nil {
+		return err
+	}
+
+	imp.ID = id
+	imp.ImportedAt = time.Now()
+	imp.RowCount = rows
+	s.imports = append(s.imports, *imp)
+	return nil
+}
+
+func (s *memoryImports) GetAll(ctx context.Context) ([]Import, error) {
+	return s.find(func(Import) bool { return true }), nil
+}
+
+func (s *memoryImports) FindByHash(ctx context.Context, hash string) ([]Import, error) {
+	return s.find(func(imp Import) bool { return imp.ContentHash == hash }), nil
+}
+
+// find returns the matching imports, newest first.
+func (s *memoryImports) find(keep func(Import) bool) []Import {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var imports []Import
+	for _, imp := range s.imports {
+		if keep(imp) {
+			imports = append(imports, imp)
+		}
+	}
+	sort.Slice(imports, func(i, j int) bool {
+		if !imports[i].ImportedAt.Equal(imports[j].ImportedAt) {
+			return imports[i].ImportedAt.After(imports[j].ImportedAt)
+		}
+		return imports[i].ID > imports[j].ID
+	})
+	return imports
+}
+
+func (s *memoryImports) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteTransactions(func(t *memoryTransaction) bool { return t.importID != id })
+
+	n := len(s.imports)
+	s.imports = slices.DeleteFunc(s.imports, func(imp Import) bool { return imp.ID == id })
+	if len(s.imports) == n {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+type memoryRules struct {
+	*memory
+}
+
+func (s *memoryRules) Insert(ctx context.Context, rule *Rule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	categoryID, err := s.categoryID(rule.CategoryName)
+	if err != nil {
+		return err
+	}
+	if rule.AccountID != 0 && !slices.ContainsFunc(s.accounts, func(a Account) bool { return a.ID == rule.AccountID }) {
+		return fmt.Errorf("account not found: %d", rule.AccountID)
+	}
+
+	rule.ID = s.nextID("category_rules")
+	stored := memoryRule{Rule: *rule, categoryID: categoryID}
+	stored.MinAmount = copyAmount(rule.MinAmount)
+	stored.MaxAmount = copyAmount(rule.MaxAmount)
+	s.rules = append(s.rules, stored)
+	return nil
+}
+
+// copyAmount copies a rule bound, which is stored without a currency.
+func copyAmount(amount *money.Money) *money.Money {
+	if amount == nil {
+		return nil
+	}
+	return &money.Money{Cents: amount.Cents}
+}
+
+func (s *memoryRules) GetAll(ctx context.Context) ([]Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rules []Rule
+	for _, r := range s.rules {
+		rule := r.Rule
+		rule.CategoryName = s.categoryName(r.categoryID)
+		rule.MinAmount = copyAmount(r.MinAmount)
+		rule.MaxAmount = copyAmount(r.MaxAmount)
+		rules = append(rules, rule)
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority > rules[j].Priority
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules, nil
+}
+
+func (s *memoryRules) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.rules)
+	s.rules = slices.DeleteFunc(s.rules, func(r memoryRule) bool { return r.ID == id })
+	if len(s.rules) == n {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+type memoryMerchants struct {
+	*memory
+}
+
+func (s *memoryMerchants) Remember(ctx context.Context, pattern, category string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	categoryID, err := s.categoryID(category)
+	if err != nil {
+		return err
+	}
+
+	for i := range s.merchants {
+		if m := &s.merchants[i]; m.Pattern == pattern {
+			m.categoryID = categoryID
+			m.Hits++
+			m.UpdatedAt = time.Now()
+			return nil
+		}
+	}
+	s.merchants = append(s.merchants, memoryMerchant{
+		Merchant:   Merchant{ID: s.nextID("merchant_memory"), Pattern: pattern, Hits: 1, UpdatedAt: time.Now()},
+		categoryID: categoryID,
+	})
+	return nil
+}
+
+func (s *memoryMerchants) GetAll(ctx context.Context) ([]Merchant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var merchants []Merchant
+	for _, m := range s.merchants {
+		merchant := m.Merchant
+		merchant.CategoryName = s.categoryName(m.categoryID)
+		merchants = append(merchants, merchant)
+	}
+	sort.Slice(merchants, func(i, j int) bool { return merchants[i].Pattern < merchants[j].Pattern })
+	return merchants, nil
+}
+
+func (s *memoryMerchants) Forget(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.merchants)
+	s.merchants = slices.DeleteFunc(s.merchants, func(m memoryMerchant) bool { return m.ID == id })
+	if len(s.merchants) == n {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+type memoryTags struct {
+	*memory
+}
+
+func (s *memoryTags) Tag(ctx context.Context, tag string, ids ...int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if NormalizeTag(tag) == "" {
+		return fmt.Errorf("empty tag")
+	}
+	for _, id := range ids {
+		if s.transaction(id) == nil {
+			return fmt.Errorf("transaction not found: %d", id)
+		}
+	}
+	s.tag(tag, ids)
+	return nil
+}
+
+func (s *memoryTags) Untag(ctx context.Context, tag string, ids ...int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := NormalizeTag(tag)
+	for _, t := range s.tags {
+		if t.Name != name {
+			continue
+		}
+		for _, id := range ids {
+			delete(s.tagged, memoryTag{id, t.ID})
+		}
+	}
+	return nil
+}
+
+func (s *memoryTags) GetAll(ctx context.Context) ([]Tag, error) {
+	return s.totals(true, func(*memoryTransaction) bool { return true }), nil
+}
+
+func (s *memoryTags) GetTotalsByDate(ctx context.Context, startDate, endDate string, accountID int64) ([]Tag, error) {
+	return s.totals(false, func(t *memoryTransaction) bool {
+		return t.Date >= startDate && t.Date <= endDate && (accountID == 0 || t.AccountID == accountID)
+	}), nil
+}
+
+// totals counts and sums the transactions of every tag that keep accepts.
+// Unused tags are only listed when all is set.
+func (s *memoryTags) totals(all bool, keep func(*memoryTransaction) bool) []Tag {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var tags []Tag
+	for _, tag := range s.tags {
+		for link := range s.tagged {
+			if t := s.transaction(link.transactionID); link.tagID == tag.ID && t != nil && keep(t) {
+				tag.Transactions++
+				tag.Total = tag.Total.Add(t.Amount)
+			}
+		}
+		if all || tag.Transactions > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags
+}
+
+func (s *memoryTags) GetTransactions(ctx context.Context, tag, startDate, endDate string) ([]Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := NormalizeTag(tag)
+	i := slices.IndexFunc(s.tags, func(t Tag) bool { return t.Name == name })
+	if i < 0 {
+		return nil, nil
+	}
+	tagID := s.tags[i].ID
+	return s.byDate(startDate, endDate, func(t *memoryTransaction) bool {
+		return s.tagged[memoryTag{t.ID, tagID}]
+	}), nil
+}
+
+func (s *memoryTags) GetByDate(ctx context.Context, startDate, endDate string) (map[int64][]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tags := make(map[int64][]string)
+	for link := range s.tagged {
+		t := s.transaction(link.transactionID)
+		if t == nil || t.Date < startDate || t.Date > endDate {
+			continue
+		}
+		for _, tag := range s.tags {
+			if tag.ID == link.tagID {
+				tags[t.ID] = append(tags[t.ID], tag.Name)
+			}
+		}
+	}
+	for _, names := range tags {
+		slices.Sort(names)
+	}
+	return tags, nil
+}
+
+func (s *memoryTags) Rename(ctx context.Context, id int64, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name = NormalizeTag(name)
+	if name == "" {
+		return fmt.Errorf("empty tag")
+	}
+	i := slices.IndexFunc(s.tags, func(t Tag) bool { return t.ID == id })
+	if i < 0 {
+		return sql.ErrNoRows
+	}
+	if slices.ContainsFunc(s.tags, func(t Tag) bool { return t.Name == name && t.ID != id }) {
+		return fmt.Errorf("tag %q already exists", name)
+	}
+	s.tags[i].Name = name
+	return nil
+}
+
+func (s *memoryTags) Delete(ctx context.Context, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.tags)
+	s.tags = slices.DeleteFunc(s.tags, func(t Tag) bool { return t.ID == id })
+	if len(s.tags) == n {
+		return sql.ErrNoRows
+	}
+	for link := range s.tagged {
+		if link.tagID == id {
+			delete(s.tagged, link)
+		}
+	}
+	return nil
+}
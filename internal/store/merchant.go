@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Merchant is a learned mapping from a normalized transaction description to
+// the category the user picked for it.
+type Merchant struct {
+	ID           int64
+	Pattern      string
+	CategoryName string
+	Hits         int
+	UpdatedAt    time.Time
+}
+
+type MerchantStore struct {
+	db *sql.DB
+}
+
+// Remember stores the category chosen for pattern, replacing any earlier
+// choice and counting how often the pattern was seen.
+func (s *MerchantStore) Remember(ctx context.Context, pattern, category string) error {
+	query := `
+		INSERT INTO merchant_memory (pattern, category_id)
+		SELECT $1, id FROM category_paths WHERE ref = $2 OR path = $2
+		ON CONFLICT (pattern) DO UPDATE
+		SET category_id = EXCLUDED.category_id,
+			hits = merchant_memory.hits + 1,
+			updated_at = CURRENT_TIMESTAMP
+	`
+
+	res, err := s.db.ExecContext(ctx, query, pattern, category)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return fmt.Errorf("category not found: %s", category)
+	}
+
+	return nil
+}
+
+func (s *MerchantStore) GetAll(ctx context.Context) ([]Merchant, error) {
+	query := `
+		SELECT m.id, m.pattern, c.ref, m.hits, m.updated_at
+		FROM merchant_memory m
+		JOIN category_paths c ON c.id = m.category_id
+		ORDER BY m.pattern
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var merchants []Merchant
+	for rows.Next() {
+		var m Merchant
+		if err := rows.Scan(&m.ID, &m.Pattern, &m.CategoryName, &m.Hits, &m.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		merchants = append(merchants, m)
+	}
+
+	return merchants, rows.Err()
+}
+
+func (s *MerchantStore) Forget(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM merchant_memory WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dylanewe/moni/internal/money"
+)
+
+// SortColumns lists the columns List can sort by. The first is the default.
+var SortColumns = []string{"date", "description", "category", "amount", "account"}
+
+// sortExpressions maps SortColumns to the expressions List orders by.
+var sortExpressions = map[string]string{
+	"date":        "t.date",
+	"description": "t.description",
+	"category":    "COALESCE(c.ref, '')",
+	"amount":      "t.amount",
+	"account":     "COALESCE(t.account_id, 0)",
+}
+
+// TransactionQuery selects a page of transactions for List. Every filter is
+// optional and the zero value lists all transactions, oldest first.
+type TransactionQuery struct {
+	StartDate string
+	EndDate   string
+	// CategoryName keeps the transactions allocated to the category or one
+	// of its subcategories, either as a whole or through a split. Like every
+	// CategoryName it is the name of the category, or its path when other
+	// categories share the name; the path always works.
+	CategoryName string
+	AccountID    int64
+	MinAmount    *money.Money
+	MaxAmount    *money.Money
+	// Description keeps the transactions whose description contains it,
+	// ignoring case.
+	Description string
+	Tag         string
+
+	Sort       string
+	Descending bool
+	// Limit caps the page size. Zero returns every match in one page.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+type TransactionPage struct {
+	Transactions []Transaction
+	// NextCursor fetches the following page. It is empty on the last page.
+	NextCursor string
+}
+
+// cursor is where a page ends: the sort value and ID of its last
+// transaction. Ties on the sort column are broken by ID.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+func (c cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parseCursor(s, sort string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.Sort != sort {
+		return nil, fmt.Errorf("invalid cursor %q", s)
+	}
+	if _, err := c.arg(); err != nil {
+		return nil, fmt.Errorf("invalid cursor %q", s)
+	}
+	return &c, nil
+}
+
+// arg returns the cursor value typed like its column.
+func (c cursor) arg() (any, error) {
+	if isNumericSort(c.Sort) {
+		return strconv.ParseInt(c.Value, 10, 64)
+	}
+	return c.Value, nil
+}
+
+func isNumericSort(sort string) bool {
+	return sort == "amount" || sort == "account"
+}
+
+// sortValue returns the value of t that the sort column orders by.
+func sortValue(t Transaction, sort string) string {
+	switch sort {
+	case "description":
+		return t.Description
+	case "category":
+		return t.CategoryName
+	case "amount":
+		return strconv.FormatInt(t.Amount.Cents, 10)
+	case "account":
+		return strconv.FormatInt(t.AccountID, 10)
+	}
+	return t.Date
+}
+
+// checkQuery fills in the default sort and validates q.
+func checkQuery(q *TransactionQuery) (*cursor, error) {
+	if q.Sort == "" {
+		q.Sort = SortColumns[0]
+	}
+	if _, ok := sortExpressions[q.Sort]; !ok {
+		return nil, fmt.Errorf("cannot sort by %q", q.Sort)
+	}
+	if q.Limit < 0 {
+		return nil, fmt.Errorf("invalid limit %d", q.Limit)
+	}
+	return parseCursor(q.Cursor, q.Sort)
+}
+
+// nextPage trims a result fetched with one row more than the limit and sets
+// the cursor of the following page.
+func nextPage(q TransactionQuery, transactions []Transaction) TransactionPage {
+	if q.Limit == 0 || len(transactions) <= q.Limit {
+		return TransactionPage{Transactions: transactions}
+	}
+	transactions = transactions[:q.Limit]
+	last := transactions[len(transactions)-1]
+	next := cursor{Sort: q.Sort, Value: sortValue(last, q.Sort), ID: last.ID}
+	return TransactionPage{Transactions: transactions, NextCursor: next.String()}
+}
+
+// List returns the transactions matching q, one page at a time.
+func (s *TransactionStore) List(ctx context.Context, q TransactionQuery) (TransactionPage, error) {
+	after, err := checkQuery(&q)
+	if err != nil {
+		return TransactionPage{}, err
+	}
+
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	where := q.filters(arg)
+
+	expr := sortExpressions[q.Sort]
+	cmp, dir := ">", "ASC"
+	if q.Descending {
+		cmp, dir = "<", "DESC"
+	}
+	if after != nil {
+		value, _ := after.arg()
+		v, id := arg(value), arg(after.ID)
+		where = append(where, fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND t.id %[2]s %[4]s))", expr, cmp, v, id))
+	}
+
+	query := `
+		SELECT t.id, t.description, COALESCE(c.ref, ''), t.amount, COALESCE(t.currency, ''), t.date, COALESCE(t.fitid, ''), COALESCE(t.account_id, 0), COALESCE(t.notes, '')
+		FROM transactions t
+		LEFT JOIN category_paths c ON c.id = t.category_id`
+	if len(where) > 0 {
+		query += "\n\t\tWHERE " + strings.Join(where, "\n\t\t\tAND ")
+	}
+	query += fmt.Sprintf("\n\t\tORDER BY %s %s, t.id %s", expr, dir, dir)
+	if q.Limit > 0 {
+		query += "\n\t\tLIMIT " + arg(q.Limit+1)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return TransactionPage{}, fmt.Errorf("failed to list transactions: %w", err)
+	}
+	defer rows.Close()
+
+	transactions, err := scanTransactions(rows)
+	if err != nil {
+		return TransactionPage{}, err
+	}
+	return nextPage(q, transactions), nil
+}
+
+// filters returns the WHERE conditions of q over transactions t joined with
+// category_paths c. arg adds a query argument and returns its placeholder.
+func (q TransactionQuery) filters(arg func(any) string) []string {
+	var where []string
+	if q.StartDate != "" {
+		where = append(where, "t.date >= "+arg(q.StartDate))
+	}
+	if q.EndDate != "" {
+		where = append(where, "t.date <= "+arg(q.EndDate))
+	}
+	if q.CategoryName != "" {
+		where = append(where, `EXISTS (
+			WITH RECURSIVE tree AS (
+				SELECT id FROM category_paths WHERE ref = `+arg(q.CategoryName)+` OR path = `+arg(q.CategoryName)+`
+				UNION
+				SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id
+			)
+			SELECT 1 FROM allocations a JOIN tree ON tree.id = a.category_id
+			WHERE a.transaction_id = t.id)`)
+	}
+	if q.AccountID != 0 {
+		where = append(where, "t.account_id = "+arg(q.AccountID))
+	}
+	if q.MinAmount != nil {
+		where = append(where, "t.amount >= "+arg(q.MinAmount.Cents))
+	}
+	if q.MaxAmount != nil {
+		where = append(where, "t.amount <= "+arg(q.MaxAmount.Cents))
+	}
+	if q.Description != "" {
+		pattern := "%" + escapeLike(strings.ToLower(q.Description)) + "%"
+		where = append(where, "LOWER(t.description) LIKE "+arg(pattern)+` ESCAPE '\'`)
+	}
+	if q.Tag != "" {
+		where = append(where, `EXISTS (
+			SELECT 1 FROM transaction_tags tt
+			JOIN tags tg ON tg.id = tt.tag_id
+			WHERE tt.transaction_id = t.id AND tg.name = `+arg(NormalizeTag(q.Tag))+`)`)
+	}
+	return where
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// escapeLike makes s match itself literally in a LIKE pattern.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dylanewe/moni/internal/money"
+)
+
+// Rule assigns a category to transactions whose description matches Pattern.
+// MinAmount and MaxAmount bound the absolute amount and AccountID, when set,
+// limits the rule to transactions imported into that account.
+type Rule struct {
+	ID           int64
+	Name         string
+	Pattern      string
+	IsRegex      bool
+	MinAmount    *money.Money
+	MaxAmount    *money.Money
+	AccountID    int64
+	CategoryName string
+	Priority     int
+}
+
+type RuleStore struct {
+	db *sql.DB
+}
+
+func (s *RuleStore) Insert(ctx context.Context, rule *Rule) error {
+	var categoryID int64
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM category_paths WHERE ref = $1 OR path = $1`, rule.CategoryName).Scan(&categoryID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("category not found: %s", rule.CategoryName)
+	}
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO category_rules (name, pattern, is_regex, min_amount, max_amount, account_id, category_id, priority)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+
+	return s.db.QueryRowContext(ctx, query, rule.Name, rule.Pattern, rule.IsRegex,
+		rule.MinAmount, rule.MaxAmount, nullInt64(rule.AccountID), categoryID, rule.Priority).Scan(&rule.ID)
+}
+
+// GetAll returns every rule in the order they should be tried: highest
+// priority first, then oldest first.
+func (s *RuleStore) GetAll(ctx context.Context) ([]Rule, error) {
+	query := `
+		SELECT r.id, r.name, r.pattern, r.is_regex, r.min_amount, r.max_amount, COALESCE(r.account_id, 0), c.ref, r.priority
+		FROM category_rules r
+		JOIN category_paths c ON c.id = r.category_id
+		ORDER BY r.priority DESC, r.id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []Rule
+	for rows.Next() {
+		var r Rule
+		var minAmount, maxAmount sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.Name, &r.Pattern, &r.IsRegex, &minAmount, &maxAmount, &r.AccountID, &r.CategoryName, &r.Priority); err != nil {
+			return nil, err
+		}
+		if minAmount.Valid {
+			r.MinAmount = &money.Money{Cents: minAmount.Int64}
+		}
+		if maxAmount.Valid {
+			r.MaxAmount = &money.Money{Cents: maxAmount.Int64}
+		}
+
+		rules = append(rules, r)
+	}
+
+	return rules, rows.Err()
+}
+
+func (s *RuleStore) Delete(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM category_rules WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,192 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// SearchQuery looks for Text in the descriptions and notes of the
+// transactions in an optional date range and category.
+type SearchQuery struct {
+	Text         string
+	StartDate    string
+	EndDate      string
+	CategoryName string
+	// Limit caps the number of results. Zero returns every match.
+	Limit int
+}
+
+// SearchResult is a matching transaction. Results with a higher Rank match
+// better; ranks are only comparable within one search.
+type SearchResult struct {
+	Transaction
+	Rank float64
+}
+
+func isPostgres(db *sql.DB) bool {
+	_, ok := db.Driver().(*stdlib.Driver)
+	return ok
+}
+
+// Search returns the transactions matching q, best first. Postgres matches
+// whole words with its full-text index and misspelled ones with trigrams;
+// other databases rank the candidates with rankTransactions instead.
+func (s *TransactionStore) Search(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	if strings.TrimSpace(q.Text) == "" {
+		return nil, fmt.Errorf("empty search")
+	}
+	if !s.postgres {
+		page, err := s.List(ctx, TransactionQuery{StartDate: q.StartDate, EndDate: q.EndDate, CategoryName: q.CategoryName})
+		if err != nil {
+			return nil, err
+		}
+		return rankTransactions(q, page.Transactions), nil
+	}
+
+	args := []any{q.Text}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	where := TransactionQuery{StartDate: q.StartDate, EndDate: q.EndDate, CategoryName: q.CategoryName}.filters(arg)
+
+	// The document expression must match idx_transactions_search.
+	query := `
+		SELECT t.id, t.description, COALESCE(c.ref, ''), t.amount, COALESCE(t.currency, ''), t.date, COALESCE(t.fitid, ''), COALESCE(t.account_id, 0), COALESCE(t.notes, ''),
+			ts_rank(to_tsvector('simple', t.description || ' ' || COALESCE(t.notes, '')), ts)
+				+ GREATEST(word_similarity($1, t.description), word_similarity($1, COALESCE(t.notes, ''))) AS rank
+		FROM transactions t
+		LEFT JOIN category_paths c ON c.id = t.category_id
+		CROSS JOIN websearch_to_tsquery('simple', $1) ts
+		WHERE (to_tsvector('simple', t.description || ' ' || COALESCE(t.notes, '')) @@ ts
+			OR $1 <% t.description
+			OR $1 <% t.notes)`
+	for _, w := range where {
+		query += "\n\t\t\tAND " + w
+	}
+	query += "\n\t\tORDER BY rank DESC, t.date DESC, t.id DESC"
+	if q.Limit > 0 {
+		query += "\n\t\tLIMIT " + arg(q.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var results []SearchResult
+	for rows.Next() {
+		var r SearchResult
+		if err := scanTransaction(rows, &r.Transaction, &r.Rank); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+
+	return results, rows.Err()
+}
+
+// minSimilarity is how alike two words must be, by their trigrams, for a
+// misspelled search term to still match.
+const minSimilarity = 0.4
+
+// rankTransactions searches candidates in Go, for databases without full-text
+// and trigram indexes. Every word of the search must match a word of the
+// description or notes: exactly, as a prefix or substring, or failing that by
+// trigram similarity. The rank is the average of how well each word matched.
+func rankTransactions(q SearchQuery, candidates []Transaction) []SearchResult {
+	terms := searchWords(q.Text)
+
+	var results []SearchResult
+	for _, t := range candidates {
+		words := searchWords(t.Description + " " + t.Notes)
+		var rank float64
+		for _, term := range terms {
+			best := 0.0
+			for _, word := range words {
+				best = max(best, matchWord(term, word))
+			}
+			if best == 0 {
+				rank = 0
+				break
+			}
+			rank += best / float64(len(terms))
+		}
+		if rank > 0 {
+			results = append(results, SearchResult{Transaction: t, Rank: rank})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Rank != b.Rank {
+			return a.Rank > b.Rank
+		}
+		if a.Date != b.Date {
+			return a.Date > b.Date
+		}
+		return a.ID > b.ID
+	})
+	if q.Limit > 0 && len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	return results
+}
+
+// searchWords splits s into lowercase words of letters and digits.
+func searchWords(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// matchWord scores how well word matches the search term, from 0 for no
+// match to 1 for the same word.
+func matchWord(term, word string) float64 {
+	switch {
+	case term == word:
+		return 1
+	case strings.HasPrefix(word, term):
+		return 0.9
+	case strings.Contains(word, term):
+		return 0.7
+	}
+	if sim := similarity(term, word); sim >= minSimilarity {
+		return 0.6 * sim
+	}
+	return 0
+}
+
+// similarity compares the trigrams of two words the way pg_trgm does: the
+// words are padded with two spaces in front and one behind, and the result
+// is the share of distinct trigrams they have in common.
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	total := len(ta) + len(tb) - shared
+	if total == 0 {
+		return 0
+	}
+	return float64(shared) / float64(total)
+}
+
+func trigrams(word string) map[string]bool {
+	r := []rune("  " + word + " ")
+	set := make(map[string]bool, len(r))
+	for i := 0; i+3 <= len(r); i++ {
+		set[string(r[i:i+3])] = true
+	}
+	return set
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/dylanewe/moni/internal/money"
+)
+
+// Split allocates part of a transaction to a category. The splits of a
+// transaction always add up to its amount.
+type Split struct {
+	CategoryName string
+	Amount       money.Money
+}
+
+// SplitRemainder returns how much of amount is not yet allocated by splits.
+// Splits in another currency than amount allocate nothing of it;
+// ValidateSplits rejects them.
+func SplitRemainder(amount money.Money, splits []Split) money.Money {
+	for _, s := range splits {
+		if r, err := amount.TrySub(s.Amount); err == nil {
+			amount = r
+		}
+	}
+	return amount
+}
+
+// ValidateSplits checks that splits allocate exactly amount. No splits at all
+// is valid and means the transaction is not split.
+func ValidateSplits(amount money.Money, splits []Split) error {
+	if len(splits) == 0 {
+		return nil
+	}
+	if len(splits) == 1 {
+		return fmt.Errorf("a split needs at least two parts")
+	}
+	for _, s := range splits {
+		if s.CategoryName == "" {
+			return fmt.Errorf("every split needs a category")
+		}
+		if s.Amount.IsZero() {
+			return fmt.Errorf("split for %s has no amount", s.CategoryName)
+		}
+		if _, err := amount.TryAdd(s.Amount); err != nil {
+			return fmt.Errorf("split for %s is in %s, not %s like its transaction", s.CategoryName, s.Amount.Currency, amount.Currency)
+		}
+	}
+	if r := SplitRemainder(amount, splits); !r.IsZero() {
+		return fmt.Errorf("splits are off by %s", r.Decimal())
+	}
+	return nil
+}
+
+// Split replaces the splits of a transaction. An empty list removes them.
+func (s *TransactionStore) Split(ctx context.Context, id int64, splits []Split) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		var amount money.Money
+		var currency string
+		query := `SELECT amount, COALESCE(currency, '') FROM transactions WHERE id = $1`
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&amount, &currency); err != nil {
+			return err
+		}
+		amount = amount.In(currency)
+		if err := ValidateSplits(amount, splits); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM transaction_splits WHERE transaction_id = $1`, id); err != nil {
+			return err
+		}
+
+		categoryMap, err := getCategoryMap(ctx, tx)
+		if err != nil {
+			return err
+		}
+		return insertSplits(ctx, tx, categoryMap, id, splits)
+	})
+}
+
+func (s *TransactionStore) GetSplits(ctx context.Context, id int64) ([]Split, error) {
+	query := `
+		SELECT COALESCE(c.ref, ''), s.amount, COALESCE(t.currency, '')
+		FROM transaction_splits s
+		JOIN transactions t ON t.id = s.transaction_id
+		LEFT JOIN category_paths c ON c.id = s.category_id
+		WHERE s.transaction_id = $1
+		ORDER BY s.id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get splits: %w", err)
+	}
+	defer rows.Close()
+
+	var splits []Split
+	for rows.Next() {
+		var split Split
+		var currency string
+		if err := rows.Scan(&split.CategoryName, &split.Amount, &currency); err != nil {
+			return nil, err
+		}
+		split.Amount.Currency = currency
+		splits = append(splits, split)
+	}
+
+	return splits, rows.Err()
+}
+
+func insertSplits(ctx context.Context, tx *sql.Tx, categoryMap categoryMap, id int64, splits []Split) error {
+	for _, split := range splits {
+		categoryID, err := categoryMap.lookup(split.CategoryName)
+		if err != nil {
+			return err
+		}
+
+		query := `INSERT INTO transaction_splits (transaction_id, category_id, amount) VALUES ($1, $2, $3)`
+		if _, err := tx.ExecContext(ctx, query, id, categoryID, split.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,29 +3,82 @@ package store
 import (
 	"context"
 	"database/sql"
+
+	"github.com/dylanewe/moni/internal/money"
 )
 
 type Store struct {
 	Transactions interface {
 		Insert(context.Context, []Transaction) error
-		GetIncomeByDate(ctx context.Context, startDate, endDate string) (float64, error)
-		GetExpenseByDate(ctx context.Context, startDate, endDate string) (float64, error)
+		GetAll(context.Context) ([]Transaction, error)
+		Get(ctx context.Context, id int64) (Transaction, error)
+		Update(context.Context, *Transaction) error
+		Delete(ctx context.Context, id int64) error
+		SetMissingCurrency(ctx context.Context, currency string) (int64, error)
+		List(context.Context, TransactionQuery) (TransactionPage, error)
+		Search(context.Context, SearchQuery) ([]SearchResult, error)
+		Split(ctx context.Context, id int64, splits []Split) error
+		GetSplits(ctx context.Context, id int64) ([]Split, error)
+		GetByDate(ctx context.Context, startDate, endDate string) ([]Transaction, error)
+		GetIncomeByDate(ctx context.Context, startDate, endDate string) (money.Totals, error)
+		GetExpenseByDate(ctx context.Context, startDate, endDate string) (money.Totals, error)
 	}
 	Categories interface {
 		Insert(context.Context, *Category) error
 		GetAll(context.Context) ([]Category, error)
+		Rename(ctx context.Context, id int64, name string) error
+		SetParent(ctx context.Context, id, parentID int64) error
+		Merge(ctx context.Context, from, into int64) error
+		Delete(ctx context.Context, id, moveTo int64) error
 	}
 	Dashboard interface {
-		GetTotalIncomeAndExpense() (float64, float64, error)
-		GetMonthlyIncomeAndExpense(year int, month int) (float64, float64, error)
+		GetTotalIncomeAndExpense(accountID int64) (money.Totals, money.Totals, error)
+		GetMonthlyIncomeAndExpense(year int, month int, accountID int64) (money.Totals, money.Totals, error)
+		GetMonthlyCategoryTotals(year int, month int, accountID int64) ([]CategoryTotal, error)
+	}
+	Accounts interface {
+		Insert(context.Context, *Account) error
+		GetAll(context.Context) ([]Account, error)
+		Delete(ctx context.Context, id int64) error
+	}
+	Imports interface {
+		Create(context.Context, *Import, []Transaction) error
+		GetAll(context.Context) ([]Import, error)
+		FindByHash(ctx context.Context, hash string) ([]Import, error)
+		Delete(ctx context.Context, id int64) error
+	}
+	Rules interface {
+		Insert(context.Context, *Rule) error
+		GetAll(context.Context) ([]Rule, error)
+		Delete(ctx context.Context, id int64) error
+	}
+	Tags interface {
+		Tag(ctx context.Context, tag string, ids ...int64) error
+		Untag(ctx context.Context, tag string, ids ...int64) error
+		GetAll(context.Context) ([]Tag, error)
+		GetTotalsByDate(ctx context.Context, startDate, endDate string, accountID int64) ([]Tag, error)
+		GetTransactions(ctx context.Context, tag, startDate, endDate string) ([]Transaction, error)
+		GetByDate(ctx context.Context, startDate, endDate string) (map[int64][]string, error)
+		Rename(ctx context.Context, id int64, name string) error
+		Delete(ctx context.Context, id int64) error
+	}
+	Merchants interface {
+		Remember(ctx context.Context, pattern, category string) error
+		GetAll(context.Context) ([]Merchant, error)
+		Forget(ctx context.Context, id int64) error
 	}
 }
 
 func NewStore(db *sql.DB) Store {
 	return Store{
-		Transactions: &TransactionStore{db},
+		Transactions: &TransactionStore{db: db, postgres: isPostgres(db)},
 		Categories:   &CategoryStore{db},
 		Dashboard:    &DashboardStore{db},
+		Accounts:     &AccountStore{db},
+		Imports:      &ImportStore{db},
+		Rules:        &RuleStore{db},
+		Merchants:    &MerchantStore{db},
+		Tags:         &TagStore{db},
 	}
 }
 
@@ -42,3 +95,23 @@ func withTx(db *sql.DB, ctx context.Context, fn func(*sql.Tx) error) error {
 
 	return tx.Commit()
 }
+
+// expectRow returns sql.ErrNoRows when a statement touched no rows.
+func expectRow(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullInt64(i int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: i, Valid: i != 0}
+}
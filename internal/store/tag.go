@@ -0,0 +1,222 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/dylanewe/moni/internal/money"
+)
+
+// Tag is a free-form label such as "tax-deductible" that cuts across
+// categories. Transactions and Total are only set by the aggregate queries.
+type Tag struct {
+	ID           int64
+	Name         string
+	Transactions int64
+	Total        money.Totals
+}
+
+type TagStore struct {
+	db *sql.DB
+}
+
+// NormalizeTag trims and lowercases a tag name and joins its words with
+// dashes, so "Trip Japan" and "trip-japan" are the same tag.
+func NormalizeTag(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), "-")
+}
+
+// Tag adds the tag, creating it if needed, to the given transactions.
+func (s *TagStore) Tag(ctx context.Context, tag string, ids ...int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		return tagTransactions(ctx, tx, tag, ids)
+	})
+}
+
+func tagTransactions(ctx context.Context, tx *sql.Tx, tag string, ids []int64) error {
+	name := NormalizeTag(tag)
+	if name == "" {
+		return fmt.Errorf("empty tag")
+	}
+
+	var tagID int64
+	query := `
+		INSERT INTO tags (name) VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	`
+	if err := tx.QueryRowContext(ctx, query, name).Scan(&tagID); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		query := `
+			INSERT INTO transaction_tags (transaction_id, tag_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`
+		if _, err := tx.ExecContext(ctx, query, id, tagID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Untag removes the tag from the given transactions. The tag itself is kept.
+func (s *TagStore) Untag(ctx context.Context, tag string, ids ...int64) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			DELETE FROM transaction_tags
+			WHERE transaction_id = $1
+				AND tag_id = (SELECT id FROM tags WHERE name = $2)
+		`
+		for _, id := range ids {
+			if _, err := tx.ExecContext(ctx, query, id, NormalizeTag(tag)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetAll returns every tag with the number and sum of its transactions.
+func (s *TagStore) GetAll(ctx context.Context) ([]Tag, error) {
+	query := `
+		SELECT tg.id, tg.name, COALESCE(t.currency, ''), COUNT(t.id), COALESCE(SUM(t.amount), 0)
+		FROM tags tg
+		LEFT JOIN transaction_tags tt ON tt.tag_id = tg.id
+		LEFT JOIN transactions t ON t.id = tt.transaction_id
+		GROUP BY tg.id, tg.name, COALESCE(t.currency, '')
+		ORDER BY tg.name, tg.id
+	`
+
+	return queryTags(ctx, s.db, query)
+}
+
+// GetTotalsByDate returns the tags used between startDate and endDate
+// inclusive, with the number and sum of their transactions in that range.
+// A non-zero accountID only counts that account's transactions.
+func (s *TagStore) GetTotalsByDate(ctx context.Context, startDate, endDate string, accountID int64) ([]Tag, error) {
+	query := `
+		SELECT tg.id, tg.name, COALESCE(t.currency, ''), COUNT(t.id), COALESCE(SUM(t.amount), 0)
+		FROM tags tg
+		JOIN transaction_tags tt ON tt.tag_id = tg.id
+		JOIN transactions t ON t.id = tt.transaction_id
+		WHERE t.date >= $1
+			AND t.date <= $2
+			AND (t.account_id = $3 OR $3 = 0)
+		GROUP BY tg.id, tg.name, COALESCE(t.currency, '')
+		ORDER BY tg.name, tg.id
+	`
+
+	return queryTags(ctx, s.db, query, startDate, endDate, accountID)
+}
+
+// queryTags reads tags that come back once per currency of their
+// transactions.
+func queryTags(ctx context.Context, db *sql.DB, query string, args ...any) ([]Tag, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []Tag
+	for rows.Next() {
+		var t Tag
+		var currency string
+		var count int64
+		var total money.Money
+		if err := rows.Scan(&t.ID, &t.Name, &currency, &count, &total); err != nil {
+			return nil, err
+		}
+		if n := len(tags); n == 0 || tags[n-1].ID != t.ID {
+			tags = append(tags, t)
+		}
+		last := &tags[len(tags)-1]
+		last.Transactions += count
+		last.Total = last.Total.Add(total.In(currency))
+	}
+
+	return tags, rows.Err()
+}
+
+// GetTransactions returns the transactions with the tag dated between
+// startDate and endDate inclusive.
+func (s *TagStore) GetTransactions(ctx context.Context, tag, startDate, endDate string) ([]Transaction, error) {
+	query := `
+		SELECT t.id, t.description, COALESCE(c.ref, ''), t.amount, COALESCE(t.currency, ''), t.date, COALESCE(t.fitid, ''), COALESCE(t.account_id, 0), COALESCE(t.notes, '')
+		FROM transactions t
+		JOIN transaction_tags tt ON tt.transaction_id = t.id
+		JOIN tags tg ON tg.id = tt.tag_id
+		LEFT JOIN category_paths c ON c.id = t.category_id
+		WHERE tg.name = $1
+			AND t.date >= $2
+			AND t.date <= $3
+		ORDER BY t.date, t.id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, NormalizeTag(tag), startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
+
+// GetByDate maps the ID of every tagged transaction dated between startDate
+// and endDate inclusive to its tags.
+func (s *TagStore) GetByDate(ctx context.Context, startDate, endDate string) (map[int64][]string, error) {
+	query := `
+		SELECT tt.transaction_id, tg.name
+		FROM transaction_tags tt
+		JOIN tags tg ON tg.id = tt.tag_id
+		JOIN transactions t ON t.id = tt.transaction_id
+		WHERE t.date >= $1
+			AND t.date <= $2
+		ORDER BY tg.name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make(map[int64][]string)
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		tags[id] = append(tags[id], name)
+	}
+
+	return tags, rows.Err()
+}
+
+func (s *TagStore) Rename(ctx context.Context, id int64, name string) error {
+	name = NormalizeTag(name)
+	if name == "" {
+		return fmt.Errorf("empty tag")
+	}
+
+	res, err := s.db.ExecContext(ctx, `UPDATE tags SET name = $2 WHERE id = $1`, id, name)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
+
+// Delete removes the tag from every transaction and deletes it.
+func (s *TagStore) Delete(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM tags WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
@@ -3,97 +3,354 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
+
+	"github.com/dylanewe/moni/internal/money"
 )
 
+const dateLayout = "2006-01-02"
+
 type Transaction struct {
-	ID           int64   `json:"-"`
-	Description  string  `json:"description"`
-	CategoryName string  `json:"category"`
-	Amount       float64 `json:"amount"`
-	Date         string  `json:"date"`
+	ID           int64       `json:"-"`
+	Description  string      `json:"description"`
+	CategoryName string      `json:"category"`
+	Amount       money.Money `json:"amount"`
+	Date         string      `json:"date"`
+	FITID        string      `json:"-"`
+	// Account names the account the statement belongs to when the parser
+	// can tell, e.g. the bank profile of a CSV export. AccountID is the
+	// stored account the transaction was imported into.
+	Account   string   `json:"-"`
+	AccountID int64    `json:"-"`
+	Notes     string   `json:"-"`
+	Tags      []string `json:"-"`
+	// Splits allocate the amount over several categories. CategoryName
+	// still holds the main category.
+	Splits []Split `json:"-"`
 }
 
 type TransactionStore struct {
 	db *sql.DB
+	// postgres enables the full-text and trigram search of Postgres.
+	postgres bool
 }
 
 func (s *TransactionStore) Insert(ctx context.Context, transactions []Transaction) error {
 	return withTx(s.db, ctx, func(tx *sql.Tx) error {
-		categoryMap, err := getCategoryMap(ctx, tx)
+		_, err := insertTransactions(ctx, tx, 0, 0, transactions)
+		return err
+	})
+}
+
+// insertTransactions inserts transactions and their tags, linking them to
+// importID when it is non-zero. Transactions without an AccountID are filed
+// under accountID. The ID of every inserted transaction is set and the
+// number of rows actually written is returned.
+func insertTransactions(ctx context.Context, tx *sql.Tx, importID, accountID int64, transactions []Transaction) (int64, error) {
+	if len(transactions) == 0 {
+		return 0, nil
+	}
+
+	categoryMap, err := getCategoryMap(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Transactions whose FITID was already imported into the same account
+	// are skipped, so an overlapping OFX statement can be imported again
+	// safely. The unique index leaves out rows without an account, so
+	// those are checked with a query first.
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO transactions (description, category_id, amount, currency, date, fitid, import_id, account_id, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (account_id, fitid) WHERE fitid IS NOT NULL DO NOTHING
+		RETURNING id
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	known, err := tx.PrepareContext(ctx, `SELECT EXISTS (SELECT 1 FROM transactions WHERE account_id IS NULL AND fitid = $1)`)
+	if err != nil {
+		return 0, err
+	}
+	defer known.Close()
+
+	var inserted int64
+	for i := range transactions {
+		t := &transactions[i]
+		categoryID, err := categoryMap.lookup(t.CategoryName)
 		if err != nil {
-			return err
+			return 0, err
+		}
+		if err := ValidateSplits(t.Amount, t.Splits); err != nil {
+			return 0, fmt.Errorf("transaction %q: %w", t.Description, err)
 		}
 
-		valueStrings := make([]string, 0, len(transactions))
-		valueArgs := make([]any, 0, len(transactions)*4)
+		if t.AccountID == 0 {
+			t.AccountID = accountID
+		}
+		if t.AccountID == 0 && t.FITID != "" {
+			var exists bool
+			if err := known.QueryRowContext(ctx, t.FITID).Scan(&exists); err != nil {
+				return 0, err
+			}
+			if exists {
+				continue
+			}
+		}
 
-		for i, t := range transactions {
-			categoryID, exists := categoryMap[t.CategoryName]
-			if !exists {
-				return fmt.Errorf("category not found: %s", t.CategoryName)
+		err = stmt.QueryRowContext(ctx, t.Description, categoryID, t.Amount, nullString(t.Amount.Currency), t.Date, nullString(t.FITID),
+			nullInt64(importID), nullInt64(t.AccountID), nullString(t.Notes)).Scan(&t.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		inserted++
+
+		if err := insertSplits(ctx, tx, categoryMap, t.ID, t.Splits); err != nil {
+			return 0, err
+		}
+
+		for _, tag := range t.Tags {
+			if err := tagTransactions(ctx, tx, tag, []int64{t.ID}); err != nil {
+				return 0, err
 			}
+		}
+	}
+
+	return inserted, nil
+}
+
+// GetAll returns every categorized transaction, oldest first.
+func (s *TransactionStore) GetAll(ctx context.Context) ([]Transaction, error) {
+	query := `
+		SELECT t.id, t.description, c.ref, t.amount, COALESCE(t.currency, ''), t.date, COALESCE(t.fitid, ''), COALESCE(t.account_id, 0), COALESCE(t.notes, '')
+		FROM transactions t
+		JOIN category_paths c ON c.id = t.category_id
+		ORDER BY t.date, t.id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
+
+// GetByDate returns the stored transactions dated between startDate and
+// endDate inclusive.
+func (s *TransactionStore) GetByDate(ctx context.Context, startDate, endDate string) ([]Transaction, error) {
+	query := `
+		SELECT t.id, t.description, COALESCE(c.ref, ''), t.amount, COALESCE(t.currency, ''), t.date, COALESCE(t.fitid, ''), COALESCE(t.account_id, 0), COALESCE(t.notes, '')
+		FROM transactions t
+		LEFT JOIN category_paths c ON c.id = t.category_id
+		WHERE t.date >= $1
+			AND t.date <= $2
+		ORDER BY t.date, t.id
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %w", err)
+	}
+	defer rows.Close()
+
+	return scanTransactions(rows)
+}
+
+// Get returns a transaction with its splits and tags, or sql.ErrNoRows.
+func (s *TransactionStore) Get(ctx context.Context, id int64) (Transaction, error) {
+	query := `
+		SELECT t.id, t.description, COALESCE(c.ref, ''), t.amount, COALESCE(t.currency, ''), t.date, COALESCE(t.fitid, ''), COALESCE(t.account_id, 0), COALESCE(t.notes, '')
+		FROM transactions t
+		LEFT JOIN category_paths c ON c.id = t.category_id
+		WHERE t.id = $1
+	`
 
-			valueStrings = append(valueStrings, fmt.Sprintf("($%d, $%d, $%d, $%d)",
-				i*4+1, i*4+2, i*4+3, i*4+4))
-			valueArgs = append(valueArgs, t.Description, categoryID, t.Amount, t.Date)
+	rows, err := s.db.QueryContext(ctx, query, id)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("failed to get transaction: %w", err)
+	}
+	transactions, err := scanTransactions(rows)
+	rows.Close()
+	if err != nil {
+		return Transaction{}, err
+	}
+	if len(transactions) == 0 {
+		return Transaction{}, sql.ErrNoRows
+	}
+	t := transactions[0]
+
+	if t.Splits, err = s.GetSplits(ctx, id); err != nil {
+		return Transaction{}, err
+	}
+
+	tags, err := s.db.QueryContext(ctx, `
+		SELECT tg.name
+		FROM transaction_tags tt
+		JOIN tags tg ON tg.id = tt.tag_id
+		WHERE tt.transaction_id = $1
+		ORDER BY tg.name
+	`, id)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("failed to get tags: %w", err)
+	}
+	defer tags.Close()
+	for tags.Next() {
+		var name string
+		if err := tags.Scan(&name); err != nil {
+			return Transaction{}, err
 		}
+		t.Tags = append(t.Tags, name)
+	}
 
-		query := fmt.Sprintf("INSERT INTO transactions (description, category_id, amount, date) VALUES %s",
-			strings.Join(valueStrings, ","))
+	return t, tags.Err()
+}
 
-		_, err = tx.ExecContext(ctx, query, valueArgs...)
+// Update saves the description, category, amount, date, account and notes of
+// a transaction. Its splits and tags are left alone, so a split transaction
+// keeps its amount unless the splits are changed first. An empty category
+// name leaves the transaction uncategorized.
+func (s *TransactionStore) Update(ctx context.Context, t *Transaction) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		categoryMap, err := getCategoryMap(ctx, tx)
 		if err != nil {
 			return err
 		}
+		var categoryID int64
+		if t.CategoryName != "" {
+			if categoryID, err = categoryMap.lookup(t.CategoryName); err != nil {
+				return err
+			}
+		}
 
+		query := `
+			UPDATE transactions
+			SET description = $2, category_id = $3, amount = $4, currency = $5, date = $6, account_id = $7, notes = $8
+			WHERE id = $1
+		`
+		res, err := tx.ExecContext(ctx, query, t.ID, t.Description, nullInt64(categoryID), t.Amount,
+			nullString(t.Amount.Currency), t.Date, nullInt64(t.AccountID), nullString(t.Notes))
+		if err != nil {
+			return err
+		}
+		if err := expectRow(res); err != nil {
+			return err
+		}
+
+		var splits int64
+		var allocated money.Money
+		query = `SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM transaction_splits WHERE transaction_id = $1`
+		if err := tx.QueryRowContext(ctx, query, t.ID).Scan(&splits, &allocated); err != nil {
+			return err
+		}
+		if r := t.Amount.Sub(allocated); splits > 0 && !r.IsZero() {
+			return fmt.Errorf("splits are off by %s", r.Decimal())
+		}
 		return nil
 	})
 }
 
-func (s *TransactionStore) GetIncomeByDate(ctx context.Context, startDate, endDate string) (float64, error) {
-	var income sql.NullFloat64
-
-	query := `
-		SELECT COALESCE(SUM(amount), 0)
-		FROM transactions
-		WHERE date::date >= $1::date
-			AND date::date <= $2::date
-			AND amount >= 0
-	`
+// Delete removes a transaction along with its splits and tags.
+func (s *TransactionStore) Delete(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM transactions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	return expectRow(res)
+}
 
-	err := s.db.QueryRow(query, startDate, endDate).Scan(&income)
+// SetMissingCurrency moves the transactions stored without a currency into
+// currency and returns how many there were.
+func (s *TransactionStore) SetMissingCurrency(ctx context.Context, currency string) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `UPDATE transactions SET currency = $1 WHERE currency IS NULL`, strings.ToUpper(currency))
 	if err != nil {
-		return 0, fmt.Errorf("failed to get income: %w", err)
+		return 0, err
 	}
+	return res.RowsAffected()
+}
 
-	if !income.Valid {
-		return 0, nil
+func scanTransactions(rows *sql.Rows) ([]Transaction, error) {
+	var transactions []Transaction
+	for rows.Next() {
+		var t Transaction
+		if err := scanTransaction(rows, &t); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
 	}
 
-	return income.Float64, nil
+	return transactions, rows.Err()
 }
 
-func (s *TransactionStore) GetExpenseByDate(ctx context.Context, startDate, endDate string) (float64, error) {
-	var expense sql.NullFloat64
+// scanTransaction reads the transaction columns of the current row into t,
+// and any columns that follow them into extra.
+func scanTransaction(rows *sql.Rows, t *Transaction, extra ...any) error {
+	var date time.Time
+	var currency string
+	dest := append([]any{&t.ID, &t.Description, &t.CategoryName, &t.Amount, &currency, &date, &t.FITID, &t.AccountID, &t.Notes}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	t.Amount.Currency = currency
+	t.Date = date.Format(dateLayout)
+	return nil
+}
 
+// GetIncomeByDate sums the incoming amounts dated between startDate and
+// endDate inclusive, per currency.
+func (s *TransactionStore) GetIncomeByDate(ctx context.Context, startDate, endDate string) (money.Totals, error) {
+	income, err := s.sumByCurrency(ctx, "amount >= 0", startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get income: %w", err)
+	}
+	return income, nil
+}
+
+// GetExpenseByDate sums the outgoing amounts dated between startDate and
+// endDate inclusive, per currency.
+func (s *TransactionStore) GetExpenseByDate(ctx context.Context, startDate, endDate string) (money.Totals, error) {
+	expense, err := s.sumByCurrency(ctx, "amount < 0", startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expense: %w", err)
+	}
+	return expense, nil
+}
+
+// sumByCurrency adds up the amounts dated between startDate and endDate
+// inclusive that meet condition.
+func (s *TransactionStore) sumByCurrency(ctx context.Context, condition, startDate, endDate string) (money.Totals, error) {
 	query := `
-		SELECT COALESCE(SUM(amount), 0)
+		SELECT COALESCE(currency, ''), SUM(amount)
 		FROM transactions
-		WHERE date::date >= $1::date
-			AND date::date <= $2::date
-			AND amount < 0
+		WHERE date >= $1
+			AND date <= $2
+			AND ` + condition + `
+		GROUP BY COALESCE(currency, '')
 	`
-
-	err := s.db.QueryRow(query, startDate, endDate).Scan(&expense)
+	rows, err := s.db.QueryContext(ctx, query, startDate, endDate)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get income: %w", err)
+		return nil, err
 	}
+	defer rows.Close()
 
-	if !expense.Valid {
-		return 0, nil
+	var totals money.Totals
+	for rows.Next() {
+		var currency string
+		var sum money.Money
+		if err := rows.Scan(&currency, &sum); err != nil {
+			return nil, err
+		}
+		totals = totals.Add(sum.In(currency))
 	}
-
-	return expense.Float64, nil
+	return totals, rows.Err()
 }
@@ -1,12 +1,15 @@
 package tui
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/dylanewe/moni/internal/money"
 	"github.com/dylanewe/moni/internal/store"
 )
 
@@ -16,12 +19,17 @@ type DashboardModel struct {
 	width, height int
 
 	// state
-	currentDate   time.Time
-	totalIncome   float64
-	totalExpense  float64
-	monthlyIncome float64
-	monthlyExpense float64
-	err           error
+	currentDate time.Time
+	// account indexes accounts, -1 shows all of them.
+	accounts       []store.Account
+	account        int
+	totalIncome    money.Totals
+	totalExpense   money.Totals
+	monthlyIncome  money.Totals
+	monthlyExpense money.Totals
+	categoryTotals []store.CategoryTotal
+	tagTotals      []store.Tag
+	err            error
 }
 
 func NewDashboardModel(store *store.Store, keys KeyMap) *DashboardModel {
@@ -30,6 +38,7 @@ func NewDashboardModel(store *store.Store, keys KeyMap) *DashboardModel {
 		store:       store,
 		keys:        keys,
 		currentDate: now,
+		account:     -1,
 	}
 }
 
@@ -51,11 +60,25 @@ func (m *DashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.totalExpense = msg.totalExpense
 		m.monthlyIncome = msg.monthlyIncome
 		m.monthlyExpense = msg.monthlyExpense
+		m.categoryTotals = msg.categoryTotals
+		m.tagTotals = msg.tagTotals
+		m.accounts = msg.accounts
+		if m.account >= len(m.accounts) {
+			m.account = -1
+		}
 	case tea.KeyMsg:
 		switch {
 		case key.Matches(msg, m.keys.Prev):
 			m.currentDate = m.currentDate.AddDate(0, -1, 0)
 			return m, m.fetchData()
+		case key.Matches(msg, m.keys.Account):
+			if len(m.accounts) > 0 {
+				m.account++
+				if m.account >= len(m.accounts) {
+					m.account = -1
+				}
+				return m, m.fetchData()
+			}
 		case key.Matches(msg, m.keys.Next):
 			now := time.Now()
 			if m.currentDate.Year() < now.Year() || (m.currentDate.Year() == now.Year() && m.currentDate.Month() < now.Month()) {
@@ -86,10 +109,22 @@ func (m *DashboardModel) renderTotalView() string {
 		Height(m.height - 2).
 		Padding(1)
 
-	income := fmt.Sprintf("Total Income: %.2f", m.totalIncome)
-	expense := fmt.Sprintf("Total Expense: %.2f", m.totalExpense)
+	account := "Account: all"
+	if m.account >= 0 {
+		account = "Account: " + m.accounts[m.account].Name
+	}
+	income := fmt.Sprintf("Total Income: %s", m.totalIncome)
+	expense := fmt.Sprintf("Total Expense: %s", m.totalExpense)
 
-	return style.Render(lipgloss.JoinVertical(lipgloss.Left, income, expense))
+	return style.Render(lipgloss.JoinVertical(lipgloss.Left, account, "", income, expense))
+}
+
+// accountID is the account the dashboard is limited to, 0 for all.
+func (m *DashboardModel) accountID() int64 {
+	if m.account < 0 || m.account >= len(m.accounts) {
+		return 0
+	}
+	return m.accounts[m.account].ID
 }
 
 func (m *DashboardModel) renderMonthlyView() string {
@@ -101,37 +136,105 @@ func (m *DashboardModel) renderMonthlyView() string {
 		Padding(1)
 
 	monthStr := m.currentDate.Format("January 2006")
-	income := fmt.Sprintf("Income for %s: %.2f", monthStr, m.monthlyIncome)
-	expense := fmt.Sprintf("Expense for %s: %.2f", monthStr, m.monthlyExpense)
+	income := fmt.Sprintf("Income for %s: %s", monthStr, m.monthlyIncome)
+	expense := fmt.Sprintf("Expense for %s: %s", monthStr, m.monthlyExpense)
 
-	return style.Render(lipgloss.JoinVertical(lipgloss.Left, income, expense))
+	lines := []string{income, expense}
+	if tree := categoryTree(m.categoryTotals); len(tree) > 0 {
+		lines = append(lines, "", "By category:")
+		lines = append(lines, tree...)
+	}
+	if len(m.tagTotals) > 0 {
+		lines = append(lines, "", "By tag:")
+		for _, t := range m.tagTotals {
+			lines = append(lines, fmt.Sprintf("%s: %s", t.Name, t.Total))
+		}
+	}
+
+	return style.Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
+// categoryTree lists the categories with transactions as an indented tree.
+// Parents show the totals of their whole subtree.
+func categoryTree(totals []store.CategoryTotal) []string {
+	ids := make(map[int64]bool, len(totals))
+	for _, t := range totals {
+		ids[t.ID] = true
+	}
+	children := make(map[int64][]store.CategoryTotal)
+	for _, t := range totals {
+		parent := t.ParentID
+		if !ids[parent] {
+			parent = 0
+		}
+		children[parent] = append(children[parent], t)
+	}
+
+	var lines []string
+	var walk func(parent int64, depth int)
+	walk = func(parent int64, depth int) {
+		for _, t := range children[parent] {
+			if t.Total.IsZero() {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s%s: %s", strings.Repeat("  ", depth), t.Name, t.Total))
+			walk(t.ID, depth+1)
+		}
+	}
+	walk(0, 0)
+
+	return lines
 }
 
 type dataFetchedMsg struct {
-	totalIncome   float64
-	totalExpense  float64
-	monthlyIncome float64
-	monthlyExpense float64
-	err           error
+	totalIncome    money.Totals
+	totalExpense   money.Totals
+	monthlyIncome  money.Totals
+	monthlyExpense money.Totals
+	categoryTotals []store.CategoryTotal
+	tagTotals      []store.Tag
+	accounts       []store.Account
+	err            error
 }
 
 func (m *DashboardModel) fetchData() tea.Cmd {
+	accountID := m.accountID()
 	return func() tea.Msg {
-		totalIncome, totalExpense, err := m.store.Dashboard.GetTotalIncomeAndExpense()
+		accounts, err := m.store.Accounts.GetAll(context.Background())
+		if err != nil {
+			return dataFetchedMsg{err: err}
+		}
+
+		totalIncome, totalExpense, err := m.store.Dashboard.GetTotalIncomeAndExpense(accountID)
+		if err != nil {
+			return dataFetchedMsg{err: err}
+		}
+
+		monthlyIncome, monthlyExpense, err := m.store.Dashboard.GetMonthlyIncomeAndExpense(m.currentDate.Year(), int(m.currentDate.Month()), accountID)
+		if err != nil {
+			return dataFetchedMsg{err: err}
+		}
+
+		categoryTotals, err := m.store.Dashboard.GetMonthlyCategoryTotals(m.currentDate.Year(), int(m.currentDate.Month()), accountID)
 		if err != nil {
 			return dataFetchedMsg{err: err}
 		}
 
-		monthlyIncome, monthlyExpense, err := m.store.Dashboard.GetMonthlyIncomeAndExpense(m.currentDate.Year(), int(m.currentDate.Month()))
+		first := time.Date(m.currentDate.Year(), m.currentDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+		tagTotals, err := m.store.Tags.GetTotalsByDate(context.Background(),
+			first.Format("2006-01-02"), first.AddDate(0, 1, -1).Format("2006-01-02"), accountID)
 		if err != nil {
 			return dataFetchedMsg{err: err}
 		}
 
 		return dataFetchedMsg{
-			totalIncome:   totalIncome,
-			totalExpense:  totalExpense,
-			monthlyIncome: monthlyIncome,
+			totalIncome:    totalIncome,
+			totalExpense:   totalExpense,
+			monthlyIncome:  monthlyIncome,
 			monthlyExpense: monthlyExpense,
+			categoryTotals: categoryTotals,
+			tagTotals:      tagTotals,
+			accounts:       accounts,
 		}
 	}
 }
@@ -145,4 +248,7 @@ func (m *DashboardModel) updateData(msg dataFetchedMsg) {
 	m.totalExpense = msg.totalExpense
 	m.monthlyIncome = msg.monthlyIncome
 	m.monthlyExpense = msg.monthlyExpense
+	m.categoryTotals = msg.categoryTotals
+	m.tagTotals = msg.tagTotals
+	m.accounts = msg.accounts
 }
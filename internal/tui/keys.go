@@ -7,6 +7,7 @@ type KeyMap struct {
 	List      key.Binding
 	Prev      key.Binding
 	Next      key.Binding
+	Account   key.Binding
 	Quit      key.Binding
 }
 
@@ -28,6 +29,10 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("l", "right"),
 			key.WithHelp("l/→", "next month"),
 		),
+		Account: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "switch account"),
+		),
 		Quit: key.NewBinding(
 			key.WithKeys("q", "ctrl+c"),
 			key.WithHelp("q", "quit"),
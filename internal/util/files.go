@@ -1,9 +1,13 @@
 package util
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"os"
 	"path/filepath"
 	"slices"
+	"strings"
 )
 
 func ReadFilesFromFolder(folderPath string, extensions []string) ([]string, error) {
@@ -19,7 +23,7 @@ func ReadFilesFromFolder(folderPath string, extensions []string) ([]string, erro
 		}
 
 		if len(extensions) > 0 {
-			ext := filepath.Ext(path)
+			ext := strings.ToLower(filepath.Ext(path))
 			if !slices.Contains(extensions, ext) {
 				return nil
 			}
@@ -35,3 +39,19 @@ func ReadFilesFromFolder(folderPath string, extensions []string) ([]string, erro
 
 	return contents, nil
 }
+
+// HashFile returns the hex-encoded SHA-256 of the file's contents.
+func HashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}